@@ -7,5 +7,8 @@ import (
 )
 
 func main() {
-	log.Fatal(collector.Run(cli.NewCLI()))
+	if err := collector.Run(cli.NewCLI()); err != nil {
+		log.Fatal(err)
+	}
+	log.Info("Shut down")
 }
@@ -1,26 +1,140 @@
 package cli
 
-import "gopkg.in/alecthomas/kingpin.v2"
+import (
+	"strconv"
+	"time"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// Default weak choices, signature algorithms and key algorithms are named as crypto/x509 does
+var (
+	DefaultMinRSABits              = 2048
+	DefaultMinECDSABits            = 256
+	DefaultWeakSignatureAlgorithms = []string{"MD2-RSA", "MD5-RSA", "SHA1-RSA", "DSA-SHA1", "ECDSA-SHA1"}
+	DefaultWeakPublicKeyAlgorithms = []string{"DSA"}
+)
 
 var (
 	// Version of the exporter to be set through linker ldflags
-	Version string
+	Version     string
 	metricsPath = kingpin.Flag(
 		"metrics-path", "URI path where metrics will be exposed").Default("/metrics").Short('m').String()
 	port = kingpin.Flag(
 		"port", "Port to listen on").Default("8888").String()
 	project = kingpin.Flag(
-		"project", "GCP project where to fetch certificates from").Required().Short('p').Strings()
+		"project", "GCP project where to fetch certificates from, required unless --config.file or --probe-only is given").Short('p').Strings()
+	probeOnly = kingpin.Flag(
+		"probe-only", "Fetch from the projects given per /probe request only, without --project nor --config.file").Bool()
 	onlyInUse = kingpin.Flag(
 		"only-in-use", "Gather certificates in-use only").Short('o').Bool()
+	service = kingpin.Flag(
+		"service", "GCP service where to fetch certificates from").Default("compute", "cloudsql").Short('s').Enums(
+		"compute", "cloudsql", "redis", "alloydb", "saml")
+	fileGlob = kingpin.Flag(
+		"file-glob", "Glob of local PEM, DER or PKCS#12 files where to fetch certificates from").Strings()
+	pkcs12Password = kingpin.Flag(
+		"file-pkcs12-password", "Password of PKCS#12 files matched by --file-glob").Envar("PKCS12_PASSWORD").String()
+	probe = kingpin.Flag(
+		"probe", "Probe load balancer frontends with a TLS handshake per certificate hostname").Bool()
+	cloudsqlProbe = kingpin.Flag(
+		"cloudsql-probe", "Probe cloudsql instances negotiating TLS within the Postgres or MySQL protocol").Bool()
+	probeTimeout = kingpin.Flag(
+		"probe-timeout", "Timeout of every TLS handshake probe").Default("5s").Duration()
+	hostnameCoverage = kingpin.Flag(
+		"hostname-coverage", "Check url map host rules hostnames are covered by the httpsProxy certificates").Bool()
+	dnsBindings = kingpin.Flag(
+		"dns-bindings", "Match Cloud DNS records against the load balancer frontends serving each certificate").Bool()
+	sslPolicies = kingpin.Flag(
+		"ssl-policies", "Export ssl policies of HTTPS and SSL proxies along their compliance").Bool()
+	sslPolicyProfile = kingpin.Flag(
+		"ssl-policy-profile", "Least restrictive ssl policy profile a proxy is compliant with").Default("MODERN").Enum(
+		"COMPATIBLE", "MODERN", "RESTRICTED")
+	sslPolicyMinTLSVersion = kingpin.Flag(
+		"ssl-policy-min-tls-version", "Lowest ssl policy minimum TLS version a proxy is compliant with").Default("TLS_1_2").Enum(
+		"TLS_1_0", "TLS_1_1", "TLS_1_2")
+	minRSABits = kingpin.Flag(
+		"min-rsa-bits", "RSA keys smaller than this are flagged as weak").Default(strconv.Itoa(DefaultMinRSABits)).Int()
+	minECDSABits = kingpin.Flag(
+		"min-ecdsa-bits", "ECDSA curves smaller than this are flagged as weak").Default(strconv.Itoa(DefaultMinECDSABits)).Int()
+	weakSignatureAlgorithms = kingpin.Flag(
+		"weak-signature-algorithm", "Signature algorithm flagged as weak").Default(
+		DefaultWeakSignatureAlgorithms...).Strings()
+	weakKeyAlgorithms = kingpin.Flag(
+		"weak-key-algorithm", "Public key algorithm flagged as weak").Default(
+		DefaultWeakPublicKeyAlgorithms...).Strings()
+	chainValidation = kingpin.Flag(
+		"chain-validation", "Verify every certificate chain against the trust store").Bool()
+	trustStore = kingpin.Flag(
+		"trust-store", "PEM bundle of CA certificates to verify chains against instead of the system roots").String()
+	ocsp = kingpin.Flag(
+		"ocsp", "Check the revocation status of every certificate against its OCSP responder").Bool()
+	ocspTimeout = kingpin.Flag(
+		"ocsp-timeout", "Timeout of every OCSP request").Default("10s").Duration()
+	crl = kingpin.Flag(
+		"crl", "Check every certificate serial against the CRLs of its distribution points").Bool()
+	crlTimeout = kingpin.Flag(
+		"crl-timeout", "Timeout of every CRL download").Default("30s").Duration()
+	crlMaxBytes = kingpin.Flag(
+		"crl-max-bytes", "CRLs bigger than this are refused").Default("10485760").Int64()
+	scrapeTimeoutOffset = kingpin.Flag(
+		"scrape-timeout-offset", "Offset subtracted from the Prometheus scrape timeout to cancel GCP calls in time").Default("500ms").Duration()
+	stateFile = kingpin.Flag(
+		"state-file", "JSON file where certificates and rotation history are persisted to be served right after a restart").String()
+	apiEndpoints = kingpin.Flag(
+		"api-endpoint", "Base URL of a GCP API as API=URL, such as compute=https://compute-myendpoint.p.googleapis.com/compute/v1/").StringMap()
+	httpsProxy = kingpin.Flag(
+		"https-proxy", "Proxy URL of every GCP request instead of the one within HTTPS_PROXY").String()
+	apiCABundle = kingpin.Flag(
+		"api-ca-bundle", "PEM bundle of CA certificates trusted for GCP requests besides the system roots").String()
+	listenAddress = kingpin.Flag(
+		"web.listen-address", "Address to listen on as host:port, instead of --port on every interface").String()
+	webConfigFile = kingpin.Flag(
+		"web.config.file", "Web config file with TLS certificates, client CAs and basic auth users").String()
+	shutdownTimeout = kingpin.Flag(
+		"shutdown-timeout", "How long in-flight scrapes are given to complete once terminated").Default("30s").Duration()
+	configFile = kingpin.Flag(
+		"config.file", "YAML file describing projects, discovery rules and their settings, reloaded on SIGHUP or POST /-/reload").String()
 )
 
 // CLI holds command line arguments
 type CLI struct {
-	MetricsPath string
-	Port 	    string
-	Projects    []string
-	OnlyInUse   bool
+	MetricsPath             string
+	Port                    string
+	Projects                []string
+	ProbeOnly               bool
+	OnlyInUse               bool
+	Services                []string
+	FileGlobs               []string
+	PKCS12Password          string
+	Probe                   bool
+	CloudSQLProbe           bool
+	ProbeTimeout            time.Duration
+	HostnameCoverage        bool
+	DNSBindings             bool
+	SSLPolicies             bool
+	SSLPolicyProfile        string
+	SSLPolicyMinTLSVersion  string
+	MinRSABits              int
+	MinECDSABits            int
+	WeakSignatureAlgorithms []string
+	WeakKeyAlgorithms       []string
+	ChainValidation         bool
+	TrustStore              string
+	OCSP                    bool
+	OCSPTimeout             time.Duration
+	CRL                     bool
+	CRLTimeout              time.Duration
+	CRLMaxBytes             int64
+	ScrapeTimeoutOffset     time.Duration
+	StateFile               string
+	ConfigFile              string
+	APIEndpoints            map[string]string
+	HTTPSProxy              string
+	APICABundle             string
+	ListenAddress           string
+	WebConfigFile           string
+	ShutdownTimeout         time.Duration
 }
 
 // NewCLI returns a CLI
@@ -28,10 +142,45 @@ func NewCLI() *CLI {
 	kingpin.Version(Version)
 	kingpin.HelpFlag.Short('h')
 	kingpin.Parse()
+	if len(*project) == 0 && *configFile == "" && !*probeOnly {
+		kingpin.Fatalf("required flag --project not provided, nor --config.file or --probe-only")
+	}
 	return &CLI{
-		MetricsPath: *metricsPath,
-		Port:	     *port,
-		Projects:    *project,
-		OnlyInUse:	 *onlyInUse,
+		MetricsPath:             *metricsPath,
+		Port:                    *port,
+		Projects:                *project,
+		ProbeOnly:               *probeOnly,
+		OnlyInUse:               *onlyInUse,
+		Services:                *service,
+		FileGlobs:               *fileGlob,
+		PKCS12Password:          *pkcs12Password,
+		Probe:                   *probe,
+		CloudSQLProbe:           *cloudsqlProbe,
+		ProbeTimeout:            *probeTimeout,
+		HostnameCoverage:        *hostnameCoverage,
+		DNSBindings:             *dnsBindings,
+		SSLPolicies:             *sslPolicies,
+		SSLPolicyProfile:        *sslPolicyProfile,
+		SSLPolicyMinTLSVersion:  *sslPolicyMinTLSVersion,
+		MinRSABits:              *minRSABits,
+		MinECDSABits:            *minECDSABits,
+		WeakSignatureAlgorithms: *weakSignatureAlgorithms,
+		WeakKeyAlgorithms:       *weakKeyAlgorithms,
+		ChainValidation:         *chainValidation,
+		TrustStore:              *trustStore,
+		OCSP:                    *ocsp,
+		OCSPTimeout:             *ocspTimeout,
+		CRL:                     *crl,
+		CRLTimeout:              *crlTimeout,
+		CRLMaxBytes:             *crlMaxBytes,
+		ScrapeTimeoutOffset:     *scrapeTimeoutOffset,
+		StateFile:               *stateFile,
+		ConfigFile:              *configFile,
+		APIEndpoints:            *apiEndpoints,
+		HTTPSProxy:              *httpsProxy,
+		APICABundle:             *apiCABundle,
+		ListenAddress:           *listenAddress,
+		WebConfigFile:           *webConfigFile,
+		ShutdownTimeout:         *shutdownTimeout,
 	}
-}
\ No newline at end of file
+}
@@ -4,29 +4,62 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strings"
 	"testing"
 )
 
+// Runs NewCLI with args within a child process, returning how it exited
+func runCLI(t *testing.T, args ...string) error {
+	if os.Getenv("TESTING") == "true" {
+		return nil
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(executable, "-test.run=^"+t.Name()+"$")
+	cmd.Env = append(os.Environ(), "TESTING=true", "CLI_ARGS="+strings.Join(args, " "))
+	return cmd.Run()
+}
+
+// Within the child process, parses the arguments given by runCLI
+func parseChildArgs() {
+	os.Args = append([]string{"binaryName"}, strings.Fields(os.Getenv("CLI_ARGS"))...)
+	NewCLI()
+}
+
 func TestCLIWrongArgsExitCode(t *testing.T) {
 	expectedExitCode := 1
 	if os.Getenv("TESTING") == "true" {
-		NewCLI()
+		parseChildArgs()
 		return
 	}
-	cmd := exec.Command(os.Args[0], "-test.run=TestCLIWrongArgsExitCode")
-	cmd.Env = append(os.Environ(), "TESTING=true")
-	err := cmd.Run()
-	if err.Error() == fmt.Sprintf("exit status %d", expectedExitCode) {
+	// Neither --project, --config.file nor --probe-only
+	err := runCLI(t, "--port=6666")
+	if err != nil && err.Error() == fmt.Sprintf("exit status %d", expectedExitCode) {
 		return
 	}
 	t.Errorf("process ran with %v, want exit status %d", err, expectedExitCode)
 }
 
+func TestCLIProbeOnly(t *testing.T) {
+	if os.Getenv("TESTING") == "true" {
+		parseChildArgs()
+		return
+	}
+	for _, args := range [][]string{{"--probe-only"}, {"--config.file=config.yml"}} {
+		if err := runCLI(t, args...); err != nil {
+			t.Errorf("process ran with %v for %v, want a successful exit", err, args)
+		}
+	}
+}
+
 func TestCLIGoodArgumentsParse(t *testing.T) {
 	m := "/metrics-test"
 	p := "6666"
 	project := "project-1"
 
+	defer func(args []string) { os.Args = args }(os.Args)
 	os.Args = []string{
 		"binaryName",
 		fmt.Sprintf("--metrics-path=%s", m),
@@ -0,0 +1,107 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// AlloyDB has no client within the vendored google-api-go-client,
+// therefore the AlloyDB API is requested directly
+var alloydbBasePath = "https://alloydb.googleapis.com/v1/"
+
+type alloydbInstanceList struct {
+	Instances     []*alloydbInstance `json:"instances"`
+	NextPageToken string             `json:"nextPageToken"`
+	Unreachable   []string           `json:"unreachable"`
+}
+
+type alloydbInstance struct {
+	Name string `json:"name"`
+}
+
+type alloydbConnectionInfo struct {
+	PemCertificateChain []string `json:"pemCertificateChain"`
+}
+
+// Fetch CA certificates from every AlloyDB instance of every cluster across all regions
+func (c *SSLCollector) fetchFromAlloyDB(ctx context.Context) ([]*certificate, error) {
+	var projectsCertificates []*certificate
+
+	for _, project := range c.projects {
+		instances, err := c.listAlloyDBInstances(ctx, project)
+		// TODO: Return data from successfull projects in partial failures scenarios
+		if err != nil {
+			e := fmt.Sprintf("Trying to list alloydb instances in project [%s] with error [%s]", project, err)
+			return projectsCertificates, errors.New(e)
+		}
+
+		for _, instance := range instances {
+			var info alloydbConnectionInfo
+			u := fmt.Sprintf("%s%s/connectionInfo", c.apiBasePath("alloydb"), instance.Name)
+			if err := getJSON(ctx, c.httpClient, u, &info); err != nil {
+				e := fmt.Sprintf("Trying to get connection info for instance [%s] in project [%s] with error [%s]", instance.Name, project, err)
+				return projectsCertificates, errors.New(e)
+			}
+
+			certs, err := toInternalCertificates(getCertificateFromAlloyDBAPICertificate(instance, &info), project)
+			if err != nil {
+				return projectsCertificates, err
+			}
+			projectsCertificates = append(projectsCertificates, certs...)
+		}
+	}
+	return projectsCertificates, nil
+}
+
+func (c *SSLCollector) listAlloyDBInstances(ctx context.Context, project string) ([]*alloydbInstance, error) {
+	var instances []*alloydbInstance
+	pageToken := ""
+	for {
+		u := fmt.Sprintf("%sprojects/%s/locations/-/clusters/-/instances?pageToken=%s",
+			c.apiBasePath("alloydb"), url.PathEscape(project), url.QueryEscape(pageToken))
+
+		var list alloydbInstanceList
+		if err := getJSON(ctx, c.httpClient, u, &list); err != nil {
+			return nil, err
+		}
+		for _, location := range list.Unreachable {
+			log.Warnf("AlloyDB location [%s] in project [%s] is unreachable", location, project)
+		}
+		instances = append(instances, list.Instances...)
+
+		if list.NextPageToken == "" {
+			return instances, nil
+		}
+		pageToken = list.NextPageToken
+	}
+}
+
+// Every certificate of the CA chain is exported, the instance CA comes first
+func getCertificateFromAlloyDBAPICertificate(instance *alloydbInstance, info *alloydbConnectionInfo) []*gcpCertificate {
+	var gcpCerts []*gcpCertificate
+	name := alloydbInstanceName(instance.Name)
+	for i, raw := range info.PemCertificateChain {
+		gcpCerts = append(gcpCerts, &gcpCertificate{
+			name:     fmt.Sprintf("%s-%d", name, i),
+			raw:      raw,
+			service:  "alloydb",
+			instance: name,
+		})
+	}
+	return gcpCerts
+}
+
+// Instance names come in the form projects/{project}/locations/{location}/clusters/{cluster}/instances/{instance},
+// cluster ids are unique per location only
+func alloydbInstanceName(name string) string {
+	s := strings.Split(name, "/")
+	if len(s) < 5 {
+		return name
+	}
+	return fmt.Sprintf("%s-%s-%s", s[len(s)-5], s[len(s)-3], s[len(s)-1])
+}
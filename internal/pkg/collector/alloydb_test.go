@@ -0,0 +1,54 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchFromAlloyDB(t *testing.T) {
+	instance := "projects/project-1/locations/us-central1/clusters/orders/instances/primary"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/projects/project-1/locations/-/clusters/-/instances":
+			json.NewEncoder(w).Encode(alloydbInstanceList{
+				Instances: []*alloydbInstance{{Name: instance}},
+			})
+		case "/" + instance + "/connectionInfo":
+			json.NewEncoder(w).Encode(alloydbConnectionInfo{
+				PemCertificateChain: []string{pemData, pemData},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	defer func(p string) { alloydbBasePath = p }(alloydbBasePath)
+	alloydbBasePath = ts.URL + "/"
+
+	c := NewSSLCollector([]string{"project-1"}, ts.Client(), false, WithServices([]string{"alloydb"}))
+	certs, err := c.fetchFromGCP(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 2 {
+		t.Fatalf("Wrong number of certs, %d should be %d", len(certs), 2)
+	}
+	if certs[1].name != "us-central1-orders-primary-1" || certs[1].service != "alloydb" || certs[1].project != "project-1" {
+		t.Errorf("The following certificate struc is wrong %#v", certs[1])
+	}
+}
+
+func TestFetchFromAlloyDBUnexistentProject(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+	defer func(p string) { alloydbBasePath = p }(alloydbBasePath)
+	alloydbBasePath = ts.URL + "/"
+
+	c := NewSSLCollector([]string{"unexistent-project"}, ts.Client(), false)
+	if _, err := c.fetchFromAlloyDB(context.Background()); err == nil {
+		t.Error("there should have been an error")
+	}
+}
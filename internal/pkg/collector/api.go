@@ -0,0 +1,45 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"google.golang.org/api/googleapi"
+)
+
+// getJSON requests a Google REST API which has no client within the vendored
+// google-api-go-client and decodes the JSON response into v
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	return doJSON(ctx, client, "GET", url, nil, v)
+}
+
+// postJSON is getJSON for methods taking body as their JSON request
+func postJSON(ctx context.Context, client *http.Client, url string, body, v interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return doJSON(ctx, client, "POST", url, bytes.NewReader(data), v)
+}
+
+func doJSON(ctx context.Context, client *http.Client, method, url string, body io.Reader, v interface{}) error {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if err := googleapi.CheckResponse(res); err != nil {
+		return err
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
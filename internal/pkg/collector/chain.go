@@ -0,0 +1,157 @@
+package collector
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WithChainValidation enables verifying every certificate along its uploaded chain against roots,
+// the system roots are used when roots is nil
+func WithChainValidation(enabled bool, roots *x509.CertPool) Option {
+	return func(c *SSLCollector) {
+		if !enabled {
+			return
+		}
+		variableLabels := []string{"name", "project", "service"}
+		c.chainValidator = &chainValidator{
+			roots: roots,
+			chainValid: prometheus.NewDesc("gcp_ssl_chain_valid",
+				"Whether an ssl certificate chain verifies against the trust store, reason tells why it doesn't",
+				append(variableLabels, "reason"), nil),
+			selfSigned: prometheus.NewDesc("gcp_ssl_self_signed",
+				"Whether an ssl certificate is self-signed",
+				variableLabels, nil),
+		}
+	}
+}
+
+// LoadTrustStore reads a PEM bundle of CA certificates to verify chains against
+func LoadTrustStore(path string) (*x509.CertPool, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		e := fmt.Sprintf("No certificates found within trust store [%s]", path)
+		return nil, errors.New(e)
+	}
+	return pool, nil
+}
+
+type chainValidator struct {
+	roots      *x509.CertPool
+	chainValid *prometheus.Desc
+	selfSigned *prometheus.Desc
+}
+
+func (v *chainValidator) describe(ch chan<- *prometheus.Desc) {
+	ch <- v.chainValid
+	ch <- v.selfSigned
+}
+
+func (v *chainValidator) collect(ch chan<- prometheus.Metric, cert *certificate) {
+	if cert.x509 == nil {
+		return
+	}
+	labels := []string{cert.name, cert.project, cert.service}
+	reason := v.verify(cert.x509, cert.chain, time.Now())
+
+	ch <- prometheus.MustNewConstMetric(v.chainValid, prometheus.GaugeValue, boolToFloat(reason == ""),
+		append(labels, reason)...)
+	ch <- prometheus.MustNewConstMetric(v.selfSigned, prometheus.GaugeValue, boolToFloat(isSelfSigned(cert.x509)),
+		labels...)
+}
+
+// Returns why the chain isn't valid, or an empty reason when it is
+func (v *chainValidator) verify(leaf *x509.Certificate, chain []*x509.Certificate, now time.Time) string {
+	intermediates := x509.NewCertPool()
+	for _, c := range chain {
+		intermediates.AddCert(c)
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         v.roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		// CA certificates, such as cloudsql and redis ones, don't carry server authentication usage
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	// Clients ignore the common name, a leaf whose common name isn't among its SANs fails for that name.
+	// Leaves without DNS SANs aren't server certificates, such as cloudsql client ones named after a user
+	if leaf.Subject.CommonName != "" && !leaf.IsCA && len(leaf.DNSNames) > 0 {
+		opts.DNSName = leaf.Subject.CommonName
+	}
+
+	_, err := leaf.Verify(opts)
+	switch e := err.(type) {
+	case nil:
+	case x509.CertificateInvalidError:
+		if e.Reason == x509.Expired {
+			return "expired"
+		}
+		return "invalid"
+	case x509.HostnameError:
+		return "hostname_mismatch"
+	case x509.UnknownAuthorityError:
+		if isSelfSigned(chainTop(leaf, chain)) {
+			return "unknown_authority"
+		}
+		return "missing_intermediate"
+	default:
+		return "invalid"
+	}
+
+	if !inOrder(leaf, chain) {
+		return "wrong_order"
+	}
+	return ""
+}
+
+// Every certificate of the uploaded chain must be the issuer of the previous one
+func inOrder(leaf *x509.Certificate, chain []*x509.Certificate) bool {
+	previous := leaf
+	for _, c := range chain {
+		if !issuedBy(previous, c) {
+			return false
+		}
+		previous = c
+	}
+	return true
+}
+
+// Follow issuers through the uploaded chain, whatever its order, up to the last one found
+func chainTop(leaf *x509.Certificate, chain []*x509.Certificate) *x509.Certificate {
+	top := leaf
+	for range chain {
+		next := top
+		for _, c := range chain {
+			if c != top && issuedBy(top, c) {
+				next = c
+				break
+			}
+		}
+		if next == top {
+			break
+		}
+		top = next
+	}
+	return top
+}
+
+func issuedBy(c, issuer *x509.Certificate) bool {
+	return bytes.Equal(c.RawIssuer, issuer.RawSubject) && c.CheckSignatureFrom(issuer) == nil
+}
+
+func isSelfSigned(c *x509.Certificate) bool {
+	return bytes.Equal(c.RawIssuer, c.RawSubject) && c.CheckSignature(c.SignatureAlgorithm, c.RawTBSCertificate, c.Signature) == nil
+}
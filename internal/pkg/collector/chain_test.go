@@ -0,0 +1,101 @@
+package collector
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func newTestCertificate(t *testing.T, template *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(24 * time.Hour)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, key
+}
+
+// Returns a root and an intermediate CA along leaves issued by the intermediate for every common name
+func newTestChain(t *testing.T, commonNames ...string) (root, intermediate *x509.Certificate, leaves []*x509.Certificate) {
+	ca := func(cn string) *x509.Certificate {
+		return &x509.Certificate{
+			Subject:               pkix.Name{CommonName: cn},
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign,
+		}
+	}
+	root, rootKey := newTestCertificate(t, ca("Test Root"), nil, nil)
+	intermediate, intermediateKey := newTestCertificate(t, ca("Test Intermediate"), root, rootKey)
+	for _, cn := range commonNames {
+		leaf, _ := newTestCertificate(t, &x509.Certificate{
+			Subject:  pkix.Name{CommonName: cn},
+			DNSNames: []string{"www.example.com"},
+		}, intermediate, intermediateKey)
+		leaves = append(leaves, leaf)
+	}
+	return root, intermediate, leaves
+}
+
+func TestChainValidatorVerify(t *testing.T) {
+	root, intermediate, leaves := newTestChain(t, "www.example.com", "legacy.example.com")
+	leaf, legacyLeaf := leaves[0], leaves[1]
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	v := &chainValidator{roots: roots}
+
+	// Cloud SQL client certificates are named after a user and carry no SANs
+	serverCA, serverCAKey := newTestCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Google Cloud SQL Server CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	clientLeaf, _ := newTestCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "app-user"}}, serverCA, serverCAKey)
+	clientRoots := x509.NewCertPool()
+	clientRoots.AddCert(serverCA)
+
+	for _, tc := range []struct {
+		reason string
+		v      *chainValidator
+		leaf   *x509.Certificate
+		chain  []*x509.Certificate
+		now    time.Time
+	}{
+		{"", v, leaf, []*x509.Certificate{intermediate}, time.Now()},
+		{"", v, leaf, []*x509.Certificate{intermediate, root}, time.Now()},
+		{"wrong_order", v, leaf, []*x509.Certificate{root, intermediate}, time.Now()},
+		{"missing_intermediate", v, leaf, nil, time.Now()},
+		{"unknown_authority", &chainValidator{roots: x509.NewCertPool()}, leaf, []*x509.Certificate{root, intermediate}, time.Now()},
+		{"expired", v, leaf, []*x509.Certificate{intermediate}, time.Now().Add(48 * time.Hour)},
+		{"hostname_mismatch", v, legacyLeaf, []*x509.Certificate{intermediate}, time.Now()},
+		{"", &chainValidator{roots: clientRoots}, clientLeaf, nil, time.Now()},
+	} {
+		if reason := tc.v.verify(tc.leaf, tc.chain, tc.now); reason != tc.reason {
+			t.Errorf("Reason %q should be %q", reason, tc.reason)
+		}
+	}
+
+	if !isSelfSigned(root) || isSelfSigned(intermediate) || isSelfSigned(leaf) {
+		t.Error("Only the root should be self-signed")
+	}
+}
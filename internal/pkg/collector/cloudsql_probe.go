@@ -0,0 +1,235 @@
+package collector
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// Database engines whose TLS negotiation can be probed along their ports
+var cloudsqlPorts = map[string]string{
+	"postgres": "5432",
+	"mysql":    "3306",
+}
+
+const (
+	// https://www.postgresql.org/docs/current/protocol-message-formats.html
+	postgresSSLRequestCode = 80877103
+
+	// https://dev.mysql.com/doc/dev/mysql-server/latest/group__group__cs__capabilities__flags.html
+	mysqlClientLongPassword     = 0x00000001
+	mysqlClientProtocol41       = 0x00000200
+	mysqlClientSSL              = 0x00000800
+	mysqlClientSecureConnection = 0x00008000
+	mysqlMaxPacketSize          = 1 << 24
+	mysqlCharsetUTF8            = 33
+)
+
+// WithCloudSQLProbe enables the negotiation of TLS within the Postgres and MySQL wire protocols
+// against every Cloud SQL instance address, the probe stops after the handshake and never logs in
+func WithCloudSQLProbe(enabled bool, timeout time.Duration) Option {
+	return func(c *SSLCollector) {
+		if !enabled {
+			return
+		}
+		labels := []string{"name", "project", "address"}
+		c.cloudsqlProber = &cloudsqlProber{
+			timeout: timeout,
+			success: prometheus.NewDesc("gcp_ssl_cloudsql_probe_success",
+				"Whether TLS could be negotiated with a cloudsql instance",
+				labels, nil),
+			servedValidity: prometheus.NewDesc("gcp_ssl_cloudsql_served_validity_seconds",
+				"Time for the ssl certificate served by a cloudsql instance to expire",
+				labels, nil),
+			servedInfo: prometheus.NewDesc("gcp_ssl_cloudsql_served_certificate_info",
+				"Issuer of the ssl certificate served by a cloudsql instance",
+				append(labels, "issuer"), nil),
+		}
+	}
+}
+
+type cloudsqlProber struct {
+	timeout        time.Duration
+	success        *prometheus.Desc
+	servedValidity *prometheus.Desc
+	servedInfo     *prometheus.Desc
+}
+
+func (p *cloudsqlProber) describe(ch chan<- *prometheus.Desc) {
+	ch <- p.success
+	ch <- p.servedValidity
+	ch <- p.servedInfo
+}
+
+func (c *SSLCollector) probeCloudSQL(ctx context.Context, ch chan<- prometheus.Metric) {
+	svc, err := c.newSQLAdminService()
+	if err != nil {
+		log.Errorf("Trying to instantiate cloudsql service: [%s]", err)
+		return
+	}
+
+	for _, project := range c.projects {
+		instances, err := svc.Instances.List(project).Context(ctx).Do()
+		if err != nil {
+			log.Errorf("Trying to list instances for instance project [%s] with error [%s]", project, err)
+			continue
+		}
+		for _, instance := range instances.Items {
+			engine := cloudsqlEngine(instance.DatabaseVersion)
+			if engine == "" {
+				continue
+			}
+			for _, ip := range instance.IpAddresses {
+				// Outgoing addresses are used by the instance to connect out only
+				if ip.Type == "OUTGOING" {
+					continue
+				}
+				// Probes left once the scrape is done aren't started nor reported
+				if ctx.Err() != nil {
+					return
+				}
+				address := net.JoinHostPort(ip.IpAddress, cloudsqlPorts[engine])
+				labels := []string{instance.Name, project, address}
+
+				served, err := probeCloudSQLAddress(ctx, address, engine, c.cloudsqlProber.timeout)
+				if err != nil {
+					log.Errorf("Trying to probe instance [%s] at [%s] with error [%s]", instance.Name, address, err)
+					ch <- prometheus.MustNewConstMetric(c.cloudsqlProber.success, prometheus.GaugeValue, 0, labels...)
+					continue
+				}
+				c.cloudsqlProber.collect(ch, served, labels)
+			}
+		}
+	}
+}
+
+func (p *cloudsqlProber) collect(ch chan<- prometheus.Metric, served *x509.Certificate, labels []string) {
+	ch <- prometheus.MustNewConstMetric(p.success, prometheus.GaugeValue, 1, labels...)
+	ch <- prometheus.MustNewConstMetric(p.servedValidity, prometheus.GaugeValue,
+		float64(served.NotAfter.Unix()-time.Now().Unix()), labels...)
+	ch <- prometheus.MustNewConstMetric(p.servedInfo, prometheus.GaugeValue, 1,
+		append(labels, served.Issuer.CommonName)...)
+}
+
+// Only Postgres and MySQL negotiate TLS in a way that can be probed
+func cloudsqlEngine(databaseVersion string) string {
+	switch {
+	case strings.HasPrefix(databaseVersion, "POSTGRES"):
+		return "postgres"
+	case strings.HasPrefix(databaseVersion, "MYSQL"):
+		return "mysql"
+	}
+	return ""
+}
+
+// Returns the certificate presented by the database after asking for TLS within its wire protocol,
+// the certificate isn't verified as Cloud SQL server certificates aren't issued for the instance address
+func probeCloudSQLAddress(ctx context.Context, address, engine string, timeout time.Duration) (*x509.Certificate, error) {
+	conn, err := dialProbe(ctx, address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	switch engine {
+	case "postgres":
+		err = postgresSSLRequest(conn)
+	case "mysql":
+		err = mysqlSSLRequest(conn)
+	default:
+		err = errors.New("Unknown database engine")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConn := tls.Client(conn, &tls.Config{InsecureSkipVerify: true})
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, err
+	}
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, errors.New("No certificate served")
+	}
+	return certs[0], nil
+}
+
+// Sends a Postgres SSLRequest, the server answers with a single byte which is S when TLS may start
+func postgresSSLRequest(conn net.Conn) error {
+	req := make([]byte, 8)
+	binary.BigEndian.PutUint32(req[0:4], 8)
+	binary.BigEndian.PutUint32(req[4:8], postgresSSLRequestCode)
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	res := make([]byte, 1)
+	if _, err := io.ReadFull(conn, res); err != nil {
+		return err
+	}
+	if res[0] != 'S' {
+		e := fmt.Sprintf("Postgres refused SSLRequest with [%q]", res[0])
+		return errors.New(e)
+	}
+	return nil
+}
+
+// Reads the MySQL initial handshake then answers with an SSLRequest, which is the
+// handshake response truncated before any credentials
+func mysqlSSLRequest(conn net.Conn) error {
+	seq, payload, err := readMySQLPacket(conn)
+	if err != nil {
+		return err
+	}
+	if len(payload) > 0 && payload[0] == 0xff {
+		return errors.New("MySQL answered with an error packet")
+	}
+
+	// protocol version, NUL terminated server version, connection id, auth plugin data and filler
+	// come before the lower two bytes of the capability flags
+	end := 1
+	for end < len(payload) && payload[end] != 0 {
+		end++
+	}
+	offset := end + 1 + 4 + 8 + 1
+	if offset+2 > len(payload) {
+		return errors.New("MySQL initial handshake is too short")
+	}
+	capabilities := uint32(binary.LittleEndian.Uint16(payload[offset : offset+2]))
+	if capabilities&mysqlClientSSL == 0 {
+		return errors.New("MySQL server doesn't support SSL")
+	}
+
+	req := make([]byte, 4+32)
+	req[0] = 32
+	req[3] = seq + 1
+	binary.LittleEndian.PutUint32(req[4:8],
+		mysqlClientLongPassword|mysqlClientProtocol41|mysqlClientSSL|mysqlClientSecureConnection)
+	binary.LittleEndian.PutUint32(req[8:12], mysqlMaxPacketSize)
+	req[12] = mysqlCharsetUTF8
+	_, err = conn.Write(req)
+	return err
+}
+
+func readMySQLPacket(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[3], payload, nil
+}
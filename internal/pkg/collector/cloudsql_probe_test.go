@@ -0,0 +1,122 @@
+package collector
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Starts a fake database which speaks just enough of its protocol to upgrade the connection to TLS
+func newFakeDatabase(t *testing.T, startTLS func(conn net.Conn) bool) (string, *tls.Config, func()) {
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				if !startTLS(conn) {
+					return
+				}
+				tlsConn := tls.Server(conn, ts.TLS)
+				tlsConn.Handshake()
+				tlsConn.Close()
+			}(conn)
+		}
+	}()
+	return l.Addr().String(), ts.TLS, func() {
+		l.Close()
+		ts.Close()
+	}
+}
+
+func fakePostgres(t *testing.T, accept bool) func(conn net.Conn) bool {
+	return func(conn net.Conn) bool {
+		req := make([]byte, 8)
+		if _, err := io.ReadFull(conn, req); err != nil {
+			t.Error(err)
+			return false
+		}
+		if binary.BigEndian.Uint32(req[4:8]) != postgresSSLRequestCode {
+			t.Errorf("Wrong SSLRequest %v", req)
+			return false
+		}
+		if !accept {
+			conn.Write([]byte("N"))
+			return false
+		}
+		conn.Write([]byte("S"))
+		return true
+	}
+}
+
+func fakeMySQL(t *testing.T) func(conn net.Conn) bool {
+	return func(conn net.Conn) bool {
+		payload := []byte{10}
+		payload = append(payload, []byte("8.0.31-google\x00")...)
+		payload = append(payload, 1, 0, 0, 0)                // connection id
+		payload = append(payload, []byte("12345678\x00")...) // auth plugin data and filler
+		payload = append(payload, 0x00, 0x0a)                // lower capability flags with CLIENT_SSL
+		payload = append(payload, make([]byte, 20)...)
+		header := []byte{byte(len(payload)), 0, 0, 0}
+		conn.Write(append(header, payload...))
+
+		seq, req, err := readMySQLPacket(conn)
+		if err != nil {
+			t.Error(err)
+			return false
+		}
+		if seq != 1 || len(req) != 32 || binary.LittleEndian.Uint32(req[0:4])&mysqlClientSSL == 0 {
+			t.Errorf("Wrong SSLRequest %v", req)
+			return false
+		}
+		return true
+	}
+}
+
+func TestProbeCloudSQLAddress(t *testing.T) {
+	for _, tc := range []struct {
+		engine   string
+		startTLS func(conn net.Conn) bool
+		succeed  bool
+	}{
+		{"postgres", fakePostgres(t, true), true},
+		{"postgres", fakePostgres(t, false), false},
+		{"mysql", fakeMySQL(t), true},
+	} {
+		address, config, stop := newFakeDatabase(t, tc.startTLS)
+		served, err := probeCloudSQLAddress(context.Background(), address, tc.engine, time.Second)
+		stop()
+
+		if !tc.succeed {
+			if err == nil {
+				t.Errorf("%s there should have been an error", tc.engine)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %s", tc.engine, err)
+			continue
+		}
+		leaf, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fingerprint(served) != fingerprint(leaf) {
+			t.Errorf("%s served certificate %s is not the server one", tc.engine, served.Subject)
+		}
+	}
+}
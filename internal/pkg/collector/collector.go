@@ -2,20 +2,25 @@ package collector
 
 import (
 	"context"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/pem"
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	c "github.com/snebel29/prometheus-gcp-ssl-exporter/internal/pkg/cli"
 
+	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/compute/v1"
 	"google.golang.org/api/sqladmin/v1beta4"
@@ -23,52 +28,241 @@ import (
 
 // Run the collector to accept http scraping requests
 func Run(cli *c.CLI) error {
-	client, err := getHTTPClient()
+	endpoints, err := ParseAPIEndpoints(cli.APIEndpoints)
 	if err != nil {
 		return err
 	}
-	Register(cli.Projects, client, cli.OnlyInUse)
-	http.Handle(cli.MetricsPath, promhttp.Handler())
-	log.Infof("Beginning to serve on port :%s", cli.Port)
-	return http.ListenAndServe(fmt.Sprintf(":%s", cli.Port), nil)
+	base, err := NewTransport(cli.HTTPSProxy, cli.APICABundle)
+	if err != nil {
+		return err
+	}
+	apiMetrics := NewAPIMetrics(endpoints)
+	prometheus.MustRegister(apiMetrics)
+	transport := apiMetrics.Wrap(base)
+	client, err := getHTTPClient(transport)
+	if err != nil {
+		return err
+	}
+	roots, err := LoadTrustStore(cli.TrustStore)
+	if err != nil {
+		return err
+	}
+	// Cancelled on shutdown, for the collector of /probe as well
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opts := []Option{
+		WithContext(ctx),
+		WithTransport(transport),
+		WithAPIEndpoints(endpoints),
+		WithProbe(cli.Probe, cli.ProbeTimeout),
+		WithCloudSQLProbe(cli.CloudSQLProbe, cli.ProbeTimeout),
+		WithHostnameCoverage(cli.HostnameCoverage),
+		WithDNSBindings(cli.DNSBindings),
+		WithSSLPolicies(cli.SSLPolicies, cli.SSLPolicyProfile, cli.SSLPolicyMinTLSVersion),
+		WithWeakCrypto(cli.MinRSABits, cli.MinECDSABits, cli.WeakSignatureAlgorithms, cli.WeakKeyAlgorithms),
+		WithChainValidation(cli.ChainValidation, roots),
+		WithOCSP(cli.OCSP, cli.OCSPTimeout, base),
+		WithCRL(cli.CRL, cli.CRLTimeout, cli.CRLMaxBytes, base),
+	}
+	collector := NewSSLCollector(cli.Projects, client, cli.OnlyInUse, append(opts,
+		WithServices(cli.Services),
+		WithFileGlobs(cli.FileGlobs, cli.PKCS12Password),
+		WithStateFile(cli.StateFile))...)
+	var reloader *ConfigReloader
+	if cli.ConfigFile != "" {
+		if len(cli.Projects) > 0 {
+			log.Warnf("Ignoring --project as projects are read from config file [%s]", cli.ConfigFile)
+		}
+		reloader = NewConfigReloader(cli.ConfigFile, collector)
+		if err := reloader.Reload(); err != nil {
+			return err
+		}
+		prometheus.MustRegister(reloader)
+		reloader.WatchSignals()
+		http.Handle("/-/reload", reloader.Handler())
+	}
+	http.Handle(cli.MetricsPath, MetricsHandler(collector, cli.ScrapeTimeoutOffset))
+	// Local files and the state file aren't per project, probes only fetch from GCP
+	http.Handle("/probe", ProbeHandler(client, cli.OnlyInUse, cli.Services, cli.ScrapeTimeoutOffset, opts...))
+	address := cli.ListenAddress
+	if address == "" {
+		address = fmt.Sprintf(":%s", cli.Port)
+	}
+	http.Handle("/-/healthy", HealthyHandler())
+	http.Handle("/-/ready", ReadyHandler(collector))
+	go collector.Warmup()
+
+	log.Infof("Beginning to serve on %s", address)
+	term := make(chan os.Signal, 1)
+	signal.Notify(term, syscall.SIGTERM, os.Interrupt)
+	err = serveUntilTerminated(&http.Server{Addr: address}, cli.WebConfigFile, cli.ShutdownTimeout, term)
+	signal.Stop(term)
+	cancel()
+	if reloader != nil {
+		reloader.Stop()
+	}
+	return err
 }
 
 // Register instantiate as new SSL collector then registers with prometheus
-func Register(projects []string, client *http.Client, onlyInUse bool) {
-	prometheus.MustRegister(NewSSLCollector(projects, client, onlyInUse))
+func Register(projects []string, client *http.Client, onlyInUse bool, opts ...Option) {
+	prometheus.MustRegister(NewSSLCollector(projects, client, onlyInUse, opts...))
 }
 
+// DefaultServices are the GCP services certificates are fetched from when none are given
+var DefaultServices = []string{"compute", "cloudsql"}
+
 // SSLCollector represents the collector
 type SSLCollector struct {
-	sslValidity *prometheus.Desc
-	projects    []string
-	httpClient  *http.Client
-	onlyInUse   bool   // Whether we should fetch compute certs in use by httpsProxies only
+	sslValidity     *prometheus.Desc
+	redisCARotation *prometheus.Desc
+	scrapeTimedOut  *prometheus.Desc
+	crypto          *cryptoStrength
+	transparency    *certificateTransparency
+	duplicates      *duplicateDetector
+	rotations       *rotationTracker
+	projects        []string
+	services        []string
+	httpClient      *http.Client
+	onlyInUse       bool // Whether we should fetch compute certs in use by httpsProxies only
+	files           *fileSource
+	prober          *frontendProber
+	cloudsqlProber  *cloudsqlProber
+
+	hostnameUncovered *prometheus.Desc
+	dnsBinder         *dnsBinder
+	policies          *sslPolicies
+	chainValidator    *chainValidator
+	ocsp              *ocspChecker
+	crl               *crlChecker
+	state             *stateStore
+	fetchErrors       bool // Whether failing to fetch certificates fails the scrape
+	configured        *configuredTargets
+	transport         http.RoundTripper // Of the unauthenticated requests such as those for tokens
+	endpoints         map[string]string // Base URL of the overridden APIs by name
+	readiness         *readiness
+	ctx               context.Context // Of background work, done once stopped
+	cancel            context.CancelFunc
+}
+
+// Option configures optional behaviour of an SSLCollector
+type Option func(*SSLCollector)
+
+// WithServices sets the GCP services to fetch certificates from
+func WithServices(services []string) Option {
+	return func(c *SSLCollector) {
+		if len(services) > 0 {
+			c.services = services
+		}
+	}
+}
+
+// WithContext bounds the background work of the collector, such as Warmup and state file refreshes, to ctx
+func WithContext(ctx context.Context) Option {
+	return func(c *SSLCollector) {
+		c.cancel()
+		c.ctx, c.cancel = context.WithCancel(ctx)
+	}
+}
+
+// WithTransport sets the transport credentials of the config file fetch their tokens and call GCP through
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *SSLCollector) {
+		c.transport = transport
+	}
 }
 
 // NewSSLCollector Returns a new ssl collector
-func NewSSLCollector(projects []string, client *http.Client, onlyInUse bool) *SSLCollector {
+func NewSSLCollector(projects []string, client *http.Client, onlyInUse bool, opts ...Option) *SSLCollector {
 	variableLabels := []string{"name", "project", "service"}
-	return &SSLCollector{
+	c := &SSLCollector{
 		sslValidity: prometheus.NewDesc("gcp_ssl_validity_seconds",
 			"Time for an ssl certificate to expire",
 			variableLabels, nil),
-		projects:   projects,
-		httpClient: client,
-		onlyInUse:  onlyInUse,
+		redisCARotation: prometheus.NewDesc("gcp_ssl_redis_ca_rotation_in_progress",
+			"Whether a redis instance is serving more than one server CA certificate",
+			[]string{"name", "project"}, nil),
+		scrapeTimedOut: prometheus.NewDesc("gcp_ssl_scrape_timed_out",
+			"Whether fetching certificates was cut short by the scrape timeout",
+			nil, nil),
+		crypto:       newCryptoStrength(variableLabels),
+		transparency: newCertificateTransparency(variableLabels),
+		duplicates:   newDuplicateDetector(variableLabels),
+		rotations:    newRotationTracker(variableLabels),
+		projects:     projects,
+		services:     DefaultServices,
+		httpClient:   client,
+		onlyInUse:    onlyInUse,
+		configured:   &configuredTargets{},
+		readiness:    &readiness{},
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
 // Describe sends the super-set of all possible descriptors of metrics
 func (c *SSLCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.sslValidity
+	ch <- c.redisCARotation
+	ch <- c.scrapeTimedOut
+	c.crypto.describe(ch)
+	c.transparency.describe(ch)
+	c.duplicates.describe(ch)
+	c.rotations.describe(ch)
+	if c.prober != nil {
+		c.prober.describe(ch)
+	}
+	if c.cloudsqlProber != nil {
+		c.cloudsqlProber.describe(ch)
+	}
+	if c.hostnameUncovered != nil {
+		ch <- c.hostnameUncovered
+	}
+	if c.dnsBinder != nil {
+		c.dnsBinder.describe(ch)
+	}
+	if c.policies != nil {
+		c.policies.describe(ch)
+	}
+	if c.chainValidator != nil {
+		c.chainValidator.describe(ch)
+	}
+	if c.ocsp != nil {
+		c.ocsp.describe(ch)
+	}
+	if c.crl != nil {
+		c.crl.describe(ch)
+	}
+	if c.state != nil {
+		c.state.describe(ch)
+	}
+	if set := c.configured.current(); set != nil {
+		ch <- set.projectInfo
+	}
 }
 
 // Collect is called by the Prometheus registry when collecting metrics
 func (c *SSLCollector) Collect(ch chan<- prometheus.Metric) {
-	valueList, err := c.fetchFromGCP()
-	if err != nil {
+	c.collect(context.Background(), ch)
+}
+
+// Certificates fetched before ctx is done are collected anyway, but they don't update rotations nor the state file
+func (c *SSLCollector) collect(ctx context.Context, ch chan<- prometheus.Metric) {
+	// Projects are discovered once per scrape, for fetching certificates and for the features querying GCP on their own
+	set := c.configured.current()
+	valueList, targets, stale, err := c.certificates(ctx, set)
+	timedOut := ctx.Err() != nil
+	ch <- prometheus.MustNewConstMetric(c.scrapeTimedOut, prometheus.GaugeValue, boolToFloat(timedOut))
+	if timedOut {
+		log.Warnf("Scrape timed out, collecting the [%d] certificates fetched so far", len(valueList))
+	} else if err != nil {
 		log.Errorf("%s", err)
+		if c.fetchErrors {
+			ch <- prometheus.NewInvalidMetric(c.sslValidity, err)
+		}
 		return
 	}
 
@@ -87,45 +281,186 @@ func (c *SSLCollector) Collect(ch chan<- prometheus.Metric) {
 		} else {
 			ch <- metric
 		}
+		c.crypto.collect(ch, v)
+		c.transparency.collect(ch, v)
+		if c.chainValidator != nil {
+			c.chainValidator.collect(ch, v)
+		}
+		if c.ocsp != nil {
+			c.ocsp.collect(ctx, ch, v)
+		}
+	}
+	c.duplicates.collect(ch, valueList)
+	if !timedOut {
+		c.rotations.observe(valueList)
+	}
+	c.rotations.collect(ch)
+	if c.state != nil {
+		if !stale && !timedOut {
+			c.state.save(valueList, c.rotations)
+		}
+		c.state.collect(ch, stale)
+	}
+	if c.crl != nil {
+		c.crl.collect(ctx, ch, valueList)
+	}
+	c.collectRedisCARotation(ch, valueList)
+	// Features querying GCP on their own would block the scrape persisted certificates are served for
+	if stale || ctx.Err() != nil {
+		return
+	}
+	if set == nil {
+		c.collectLive(ctx, ch, valueList)
+		return
+	}
+	set.collect(ch, targets)
+	for _, t := range targets {
+		c.forTarget(t).collectLive(ctx, ch, valueList)
+	}
+}
+
+func (c *SSLCollector) collectLive(ctx context.Context, ch chan<- prometheus.Metric, valueList []*certificate) {
+	// Discovered once for both the probe and DNS bindings
+	var frontends []*frontend
+	if c.prober != nil || c.dnsBinder != nil {
+		frontends = c.discoverAllFrontends(ctx, valueList)
+	}
+	if c.prober != nil {
+		c.prober.collect(ctx, ch, frontends)
+	}
+	if c.cloudsqlProber != nil {
+		c.probeCloudSQL(ctx, ch)
+	}
+	if c.hostnameUncovered != nil {
+		c.collectHostnameCoverage(ctx, ch, valueList)
+	}
+	if c.dnsBinder != nil {
+		c.collectDNSBindings(ctx, ch, frontends)
+	}
+	if c.policies != nil {
+		c.collectSSLPolicies(ctx, ch)
 	}
 }
 
 type gcpCertificate struct {
-	name    string
-	raw     string
-	service string
+	name     string
+	raw      string
+	service  string
+	instance string
 }
 
 type certificate struct {
 	name            string
 	project         string
 	service         string
+	instance        string
 	secondsToExpire float64
+	x509            *x509.Certificate
+	chain           []*x509.Certificate // Intermediates as uploaded
 }
 
-func getHTTPClient() (*http.Client, error) {
-	c, err := google.DefaultClient(context.Background(), "")
+func getHTTPClient(transport http.RoundTripper) (*http.Client, error) {
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: transport})
+	c, err := google.DefaultClient(ctx, "")
 	if err != nil {
 		return nil, err
 	}
 	return c, nil
 }
 
-func (c *SSLCollector) fetchFromGCP() ([]*certificate, error) {
-	comp, err := c.fetchFromCompute()
-	if err != nil {
+// Persisted certificates are served while they are being refreshed, telling whether they are stale.
+// Otherwise the targets of set certificates were fetched from are returned too
+func (c *SSLCollector) certificates(ctx context.Context, set *targetSet) ([]*certificate, []*target, bool, error) {
+	if certs, stale := c.state.current(); stale {
+		c.state.refresh(c.refreshFetch, c.rotations)
+		return certs, nil, true, nil
+	}
+	targets := set.targets(ctx)
+	certs, err := c.fetch(ctx, set, targets)
+	return certs, targets, false, err
+}
+
+// Background refreshes aren't bound to any scrape, only to the collector
+func (c *SSLCollector) refreshFetch() ([]*certificate, error) {
+	return c.fetchConfigured(c.ctx)
+}
+
+// Fetches from the targets of the config currently applied
+func (c *SSLCollector) fetchConfigured(ctx context.Context) ([]*certificate, error) {
+	set := c.configured.current()
+	return c.fetch(ctx, set, set.targets(ctx))
+}
+
+// Once ctx is done the certificates fetched so far are returned along the context error
+func (c *SSLCollector) fetch(ctx context.Context, set *targetSet, targets []*target) ([]*certificate, error) {
+	gcp, err := c.fetchFromTargets(ctx, set, targets)
+	if err != nil && ctx.Err() == nil {
 		return nil, err
 	}
-	cloud, err := c.fetchFromCloudSQL()
+	files, err := c.fetchFromFiles()
 	if err != nil {
 		return nil, err
 	}
-	combined := append(comp, cloud...)
+	// Projects of discovery rules whose listing never succeeded are missing
+	if ctx.Err() == nil && set.discovered() {
+		c.readiness.set()
+	}
+	return append(gcp, files...), ctx.Err()
+}
+
+// Without a config file every project is fetched from the same services
+func (c *SSLCollector) fetchFromTargets(ctx context.Context, set *targetSet, targets []*target) ([]*certificate, error) {
+	if set == nil {
+		return c.fetchFromGCP(ctx)
+	}
+	var combined []*certificate
+	for _, t := range targets {
+		certs, err := t.fetch(ctx, c)
+		if err != nil && ctx.Err() != nil {
+			return append(combined, certs...), ctx.Err()
+		}
+		if err != nil {
+			return nil, err
+		}
+		combined = append(combined, certs...)
+	}
+	return combined, nil
+}
+
+func (c *SSLCollector) fetchers() map[string]func(context.Context) ([]*certificate, error) {
+	return map[string]func(context.Context) ([]*certificate, error){
+		"compute":  c.fetchFromCompute,
+		"cloudsql": c.fetchFromCloudSQL,
+		"redis":    c.fetchFromRedis,
+		"alloydb":  c.fetchFromAlloyDB,
+		"saml":     c.fetchFromSAML,
+	}
+}
+
+func (c *SSLCollector) fetchFromGCP(ctx context.Context) ([]*certificate, error) {
+	fetchers := c.fetchers()
+
+	var combined []*certificate
+	for _, service := range c.services {
+		f, ok := fetchers[service]
+		if !ok {
+			e := fmt.Sprintf("Unknown service [%s]", service)
+			return nil, errors.New(e)
+		}
+		certs, err := f(ctx)
+		if err != nil && ctx.Err() != nil {
+			return append(combined, certs...), ctx.Err()
+		}
+		if err != nil {
+			return nil, err
+		}
+		combined = append(combined, certs...)
+	}
 	return combined, nil
 }
 
-func (c *SSLCollector) fetchFromCloudSQL() ([]*certificate, error) {
-	svc, err := sqladmin.New(c.httpClient)
+func (c *SSLCollector) fetchFromCloudSQL(ctx context.Context) ([]*certificate, error) {
+	svc, err := c.newSQLAdminService()
 	if err != nil {
 		e := fmt.Sprintf("Trying to instantiate cloudsql service: [%s]", err)
 		return nil, errors.New(e)
@@ -134,23 +469,23 @@ func (c *SSLCollector) fetchFromCloudSQL() ([]*certificate, error) {
 	var projectsCertificates []*certificate
 
 	for _, project := range c.projects {
-		instances, err := svc.Instances.List(project).Do()
+		instances, err := svc.Instances.List(project).Context(ctx).Do()
 		if err != nil {
 			e := fmt.Sprintf("Trying to list instances for instance project [%s] with error [%s]", project, err)
-			return nil, errors.New(e)
+			return projectsCertificates, errors.New(e)
 		}
 		for _, instance := range instances.Items {
 
-			certificates, err := svc.SslCerts.List(project, instance.Name).Do()
+			certificates, err := svc.SslCerts.List(project, instance.Name).Context(ctx).Do()
 			// TODO: Return data from successfull projects in partial failures scenarios
 			if err != nil {
 				e := fmt.Sprintf("Trying to list certificates for instance [%s] in project [%s] with error [%s]", instance.Name, project, err)
-				return nil, errors.New(e)
+				return projectsCertificates, errors.New(e)
 			}
 
 			certs, err := toInternalCertificates(getCertificateFromCloudsqlAPICertificate(certificates), project)
 			if err != nil {
-				return nil, err
+				return projectsCertificates, err
 			}
 
 			projectsCertificates = append(projectsCertificates, certs...)
@@ -160,15 +495,15 @@ func (c *SSLCollector) fetchFromCloudSQL() ([]*certificate, error) {
 }
 
 // Fetch certificates from compute API which are bind to an httpsProxy
-func (c *SSLCollector) fetchFromComputeOnlyInUse(svc *compute.Service) ([]*certificate, error) {
+func (c *SSLCollector) fetchFromComputeOnlyInUse(ctx context.Context, svc *compute.Service) ([]*certificate, error) {
 	var projectsCertificates []*certificate
 
 	for _, project := range c.projects {
-		httpsProxies, err := svc.TargetHttpsProxies.List(project).Do()
+		httpsProxies, err := svc.TargetHttpsProxies.List(project).Context(ctx).Do()
 		// TODO: Return data from successfull projects in partial failures scenarios
 		if err != nil {
 			e := fmt.Sprintf("Trying to list httpsProxies in project [%s] with error [%s]", project, err)
-			return nil, errors.New(e)
+			return projectsCertificates, errors.New(e)
 		}
 
 		var m map[string]bool
@@ -185,16 +520,16 @@ func (c *SSLCollector) fetchFromComputeOnlyInUse(svc *compute.Service) ([]*certi
 				if _, exists := m[httpsProxyCertName]; exists { break }
 				m[httpsProxyCertName] = true
 
-				hc, err := svc.SslCertificates.Get(project, httpsProxyCertName).Do()
+				hc, err := svc.SslCertificates.Get(project, httpsProxyCertName).Context(ctx).Do()
 				if err != nil {
 					e := fmt.Sprintf("Trying to get certificate [%s] in project [%s] with error [%s]", httpsProxyCertName, project, err)
-					return nil, errors.New(e)
+					return projectsCertificates, errors.New(e)
 				}
 
 				certificates := &compute.SslCertificateList{Items: []*compute.SslCertificate{hc}}
 				certs, err := toInternalCertificates(getCertificateFromComputeAPICertificate(certificates), project)
 				if err != nil {
-					return nil, err
+					return projectsCertificates, err
 				}
 				projectsCertificates = append(projectsCertificates, certs...)
 			}
@@ -205,20 +540,20 @@ func (c *SSLCollector) fetchFromComputeOnlyInUse(svc *compute.Service) ([]*certi
 }
 
 // Fetch all certificates from compute API even if they are not bind to an httpsProxy
-func (c *SSLCollector) fetchFromComputeAll(svc *compute.Service) ([]*certificate, error) {
+func (c *SSLCollector) fetchFromComputeAll(ctx context.Context, svc *compute.Service) ([]*certificate, error) {
 	var projectsCertificates []*certificate
 
 	for _, project := range c.projects {
-		certificates, err := svc.SslCertificates.List(project).Do()
+		certificates, err := svc.SslCertificates.List(project).Context(ctx).Do()
 		// TODO: Return data from successfull projects in partial failures scenarios
 		if err != nil {
 			e := fmt.Sprintf("Trying to list certificates in project [%s] with error [%s]", project, err)
-			return nil, errors.New(e)
+			return projectsCertificates, errors.New(e)
 		}
 
 		certs, err := toInternalCertificates(getCertificateFromComputeAPICertificate(certificates), project)
 		if err != nil {
-			return nil, err
+			return projectsCertificates, err
 		}
 		projectsCertificates = append(projectsCertificates, certs...)
 	}
@@ -226,14 +561,14 @@ func (c *SSLCollector) fetchFromComputeAll(svc *compute.Service) ([]*certificate
 	return projectsCertificates, nil
 }
 
-func (c *SSLCollector) fetchFromCompute() ([]*certificate, error) {
-	svc, err := compute.New(c.httpClient)
+func (c *SSLCollector) fetchFromCompute(ctx context.Context) ([]*certificate, error) {
+	svc, err := c.newComputeService()
 	if err != nil {
 		e := fmt.Sprintf("Trying to instantiate compute service: [%s]", err)
 		return nil, errors.New(e)
 	}
 
-	var f func(svc *compute.Service) ([]*certificate, error)
+	var f func(ctx context.Context, svc *compute.Service) ([]*certificate, error)
 
 	if c.onlyInUse {
 		f = c.fetchFromComputeOnlyInUse
@@ -241,14 +576,49 @@ func (c *SSLCollector) fetchFromCompute() ([]*certificate, error) {
 		f = c.fetchFromComputeAll
 	}
 
-	projectsCertificates, err := f(svc)
+	projectsCertificates, err := f(ctx, svc)
 	if err != nil {
 		e := fmt.Sprintf("Trying to fetch from compute service: [%s]", err)
-		return nil, errors.New(e)	
+		return projectsCertificates, errors.New(e)	
 	}
 	return projectsCertificates, nil
 }
 
+// Certificates already fetched from compute by project and name, so they aren't requested again
+func knownComputeCertificates(certs []*certificate) map[string]*x509.Certificate {
+	known := make(map[string]*x509.Certificate)
+	for _, cert := range certs {
+		if cert.service == "compute" {
+			known[cert.project+"/"+cert.name] = cert.x509
+		}
+	}
+	return known
+}
+
+// Resolve certificate URIs bind to an httpsProxy or sslProxy, those unknown are requested and remembered
+func lookupComputeCertificates(ctx context.Context, svc *compute.Service, project string, uris []string, known map[string]*x509.Certificate) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for _, uri := range uris {
+		s := strings.Split(uri, "/")
+		name := s[len(s)-1]
+
+		cert, ok := known[project+"/"+name]
+		if !ok {
+			hc, err := svc.SslCertificates.Get(project, name).Context(ctx).Do()
+			if err != nil {
+				e := fmt.Sprintf("Trying to get certificate [%s] in project [%s] with error [%s]", name, project, err)
+				return nil, errors.New(e)
+			}
+			if cert, err = parseCertificate(hc.Certificate); err != nil {
+				return nil, err
+			}
+			known[project+"/"+name] = cert
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
 func getCertificateFromComputeAPICertificate(certs *compute.SslCertificateList) []*gcpCertificate {
 	var gcpCerts []*gcpCertificate
 	for _, c := range certs.Items {
@@ -277,10 +647,11 @@ func toInternalCertificates(gcpCertList []*gcpCertificate, project string) ([]*c
 
 	var projectsCertificates []*certificate
 	for _, cert := range gcpCertList {
-		c, err := parseCertificate(cert.raw)
+		chain, err := parseCertificateChain(cert.raw)
 		if err != nil {
 			return nil, err
 		}
+		c := chain[0]
 		secondsToExpire := float64(c.NotAfter.Unix() - time.Now().Unix())
 		log.Debugf("%v %v %v %v %v",
 			cert.name, c.NotAfter, time.Now().Unix(), secondsToExpire, c.NotAfter.Unix())
@@ -289,13 +660,25 @@ func toInternalCertificates(gcpCertList []*gcpCertificate, project string) ([]*c
 			name:            cert.name,
 			project:         project,
 			secondsToExpire: secondsToExpire,
-			service:         cert.service})
+			service:         cert.service,
+			instance:        cert.instance,
+			x509:            c,
+			chain:           chain[1:]})
 	}
 	return projectsCertificates, nil
 }
 
 func parseCertificate(raw string) (*x509.Certificate, error) {
-	var nilCertificate *x509.Certificate
+	c, err := parseCertificateChain(raw)
+	if err != nil {
+		return nil, err
+	}
+	return c[0], nil
+}
+
+// Parse every certificate within raw, the leaf first followed by the chain as uploaded
+func parseCertificateChain(raw string) ([]*x509.Certificate, error) {
+	var nilCertificate []*x509.Certificate
 	var blocks []byte
 	remainder := []byte(raw)
 	for {
@@ -313,5 +696,11 @@ func parseCertificate(raw string) (*x509.Certificate, error) {
 	if err != nil {
 		return nilCertificate, err
 	}
-	return c[0], nil
+	return c, nil
+}
+
+// SHA-256 fingerprint of the DER encoded certificate
+func fingerprint(c *x509.Certificate) string {
+	sum := sha256.Sum256(c.Raw)
+	return hex.EncodeToString(sum[:])
 }
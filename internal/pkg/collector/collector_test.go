@@ -1,8 +1,10 @@
 package collector
 
 import (
+	"context"
 	"fmt"
 	"errors"
+	"net/http"
 	"testing"
 	"github.com/seborama/govcr"
 	"github.com/prometheus/client_golang/prometheus"
@@ -68,7 +70,7 @@ func TestParseCertificate(t *testing.T) {
 
 func helperCertificateRequest(
 	t *testing.T,
-	f func() ([]*certificate, error),
+	f func(context.Context) ([]*certificate, error),
 	casseteName string,
 	c *SSLCollector,
 	numbCerts int,
@@ -83,7 +85,7 @@ func helperCertificateRequest(
 
 		c.httpClient = vcr.Client
 	
-		certs, err := f()
+		certs, err := f(context.Background())
 		if clientShouldSuceed && err != nil {
 			t.Error(err)
 		}
@@ -97,7 +99,7 @@ func helperCertificateRequest(
 }
 
 func TestFetchFromCloudSQL(t *testing.T) {
-	client, err := getHTTPClient()
+	client, err := getHTTPClient(http.DefaultTransport)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -111,7 +113,7 @@ func TestFetchFromCloudSQL(t *testing.T) {
 }
 
 func TestFetchFromComputeOnlyInUse(t *testing.T) {
-	client, err := getHTTPClient()
+	client, err := getHTTPClient(http.DefaultTransport)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -125,7 +127,7 @@ func TestFetchFromComputeOnlyInUse(t *testing.T) {
 }
 
 func TestFetchFromCompute(t *testing.T) {
-	client, err := getHTTPClient()
+	client, err := getHTTPClient(http.DefaultTransport)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -139,7 +141,7 @@ func TestFetchFromCompute(t *testing.T) {
 }
 
 func TestFetchFromGCPMultipleProjects(t *testing.T) {
-	client, err := getHTTPClient()
+	client, err := getHTTPClient(http.DefaultTransport)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -153,7 +155,7 @@ func TestFetchFromGCPMultipleProjects(t *testing.T) {
 }
 
 func TestFetchFromGCPUnexistentProjects(t *testing.T) {
-	client, err := getHTTPClient()
+	client, err := getHTTPClient(http.DefaultTransport)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -192,7 +194,7 @@ func TestToInternalCertificates(t *testing.T) {
 func TestCollect(t *testing.T) {
 	ch := make(chan prometheus.Metric)
 
-	client, err := getHTTPClient()
+	client, err := getHTTPClient(http.DefaultTransport)
 	if err != nil {
 		t.Fatal(err)
 	}
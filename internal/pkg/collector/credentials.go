@@ -0,0 +1,88 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// Base path of the IAM Credentials API, which has no client within the vendored google-api-go-client
+var iamCredentialsBasePath = "https://iamcredentials.googleapis.com/v1/"
+
+// How long generating a token may take, scrapes of the project wait for it as the token source is locked meanwhile
+var impersonationTimeout = 30 * time.Second
+
+type generateAccessTokenRequest struct {
+	Delegates []string `json:"delegates,omitempty"`
+	Scope     []string `json:"scope"`
+}
+
+type generateAccessTokenResponse struct {
+	AccessToken string    `json:"accessToken"`
+	ExpireTime  time.Time `json:"expireTime"`
+}
+
+// Token requests and the clients built from them go through the transport given to the collector
+func (c *SSLCollector) oauth2Context() context.Context {
+	if c.transport == nil {
+		return context.Background()
+	}
+	return context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: c.transport})
+}
+
+// Builds a client authenticated with the service account key or credentials file in path
+func newCredentialsFileClient(ctx context.Context, path string) (*http.Client, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := google.CredentialsFromJSON(ctx, data, cloudPlatformScope)
+	if err != nil {
+		return nil, err
+	}
+	return oauth2.NewClient(ctx, creds.TokenSource), nil
+}
+
+// Builds a client authenticated as serviceAccount, whose tokens are generated by the identity of base through
+// the IAM Credentials API at basePath. Every delegate in turn must be allowed to create tokens of the next one,
+// the last one of serviceAccount
+func newImpersonatedClient(ctx context.Context, base *http.Client, basePath, serviceAccount string, delegates []string) *http.Client {
+	ts := &impersonatedTokenSource{client: base, basePath: basePath, serviceAccount: serviceAccount, delegates: delegates}
+	return oauth2.NewClient(ctx, oauth2.ReuseTokenSource(nil, ts))
+}
+
+type impersonatedTokenSource struct {
+	client         *http.Client
+	basePath       string
+	serviceAccount string
+	delegates      []string
+}
+
+func (s *impersonatedTokenSource) Token() (*oauth2.Token, error) {
+	req := generateAccessTokenRequest{Scope: []string{cloudPlatformScope}}
+	for _, delegate := range s.delegates {
+		req.Delegates = append(req.Delegates, serviceAccountResource(delegate))
+	}
+	u := fmt.Sprintf("%s%s:generateAccessToken", s.basePath, serviceAccountResource(s.serviceAccount))
+
+	ctx, cancel := context.WithTimeout(context.Background(), impersonationTimeout)
+	defer cancel()
+	var res generateAccessTokenResponse
+	if err := postJSON(ctx, s.client, u, req, &res); err != nil {
+		e := fmt.Sprintf("Trying to impersonate service account [%s] with error [%s]", s.serviceAccount, err)
+		return nil, errors.New(e)
+	}
+	return &oauth2.Token{AccessToken: res.AccessToken, TokenType: "Bearer", Expiry: res.ExpireTime}, nil
+}
+
+func serviceAccountResource(email string) string {
+	return "projects/-/serviceAccounts/" + email
+}
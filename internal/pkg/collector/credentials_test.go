@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImpersonatedClient(t *testing.T) {
+	tokens := 0
+	iam := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/projects/-/serviceAccounts/target@p.iam.gserviceaccount.com:generateAccessToken" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		var req generateAccessTokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{"projects/-/serviceAccounts/delegate@p.iam.gserviceaccount.com"}
+		if !reflect.DeepEqual(req.Delegates, expected) || !reflect.DeepEqual(req.Scope, []string{cloudPlatformScope}) {
+			t.Errorf("Unexpected token request %+v", req)
+		}
+		tokens++
+		json.NewEncoder(w).Encode(generateAccessTokenResponse{AccessToken: "impersonated", ExpireTime: time.Now().Add(time.Hour)})
+	}))
+	defer iam.Close()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "Bearer impersonated" {
+			t.Errorf("Unexpected authorization [%s]", auth)
+		}
+	}))
+	defer api.Close()
+
+	client := newImpersonatedClient(context.Background(), http.DefaultClient, iam.URL+"/", "target@p.iam.gserviceaccount.com", []string{"delegate@p.iam.gserviceaccount.com"})
+	for i := 0; i < 2; i++ {
+		res, err := client.Get(api.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+	if tokens != 1 {
+		t.Errorf("Tokens should be reused until they expire, %d generated", tokens)
+	}
+}
+
+func TestImpersonatedClientDenied(t *testing.T) {
+	iam := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": {"code": 403, "message": "Permission denied"}}`, http.StatusForbidden)
+	}))
+	defer iam.Close()
+
+	client := newImpersonatedClient(context.Background(), http.DefaultClient, iam.URL+"/", "target@p.iam.gserviceaccount.com", nil)
+	_, err := client.Get(iam.URL)
+	if err == nil || !strings.Contains(err.Error(), "Trying to impersonate service account [target@p.iam.gserviceaccount.com]") {
+		t.Errorf("Expected an impersonation error, got [%v]", err)
+	}
+}
+
+func TestImpersonatedClientTimeout(t *testing.T) {
+	hang := make(chan struct{})
+	iam := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hang
+	}))
+	defer iam.Close()
+	defer close(hang)
+	defer func(d time.Duration) { impersonationTimeout = d }(impersonationTimeout)
+	impersonationTimeout = 50 * time.Millisecond
+
+	client := newImpersonatedClient(context.Background(), http.DefaultClient, iam.URL+"/", "target@p.iam.gserviceaccount.com", nil)
+	start := time.Now()
+	if _, err := client.Get(iam.URL); err == nil {
+		t.Errorf("Expected an impersonation error")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Generating a token should time out, took %s", time.Since(start))
+	}
+}
@@ -0,0 +1,164 @@
+package collector
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// WithCRL enables checking every certificate serial against the CRLs of its distribution points,
+// CRLs bigger than maxBytes are refused and the issuer to verify them is taken from the uploaded chain.
+// CRLs are downloaded through transport, the one of GCP requests without their metrics, or the default one when nil
+func WithCRL(enabled bool, timeout time.Duration, maxBytes int64, transport http.RoundTripper) Option {
+	return func(c *SSLCollector) {
+		if !enabled {
+			return
+		}
+		c.crl = &crlChecker{
+			client:   &http.Client{Transport: transport, Timeout: timeout},
+			maxBytes: maxBytes,
+			cache:    make(map[crlKey]*cachedCRL),
+			revoked: prometheus.NewDesc("gcp_ssl_crl_revoked",
+				"Whether an ssl certificate is revoked according to the CRLs of its distribution points",
+				[]string{"name", "project", "service"}, nil),
+			crlValidity: prometheus.NewDesc("gcp_ssl_crl_validity_seconds",
+				"Time for a CRL to reach its next update, negative once it is stale",
+				[]string{"url"}, nil),
+		}
+	}
+}
+
+type crlChecker struct {
+	client      *http.Client
+	maxBytes    int64
+	revoked     *prometheus.Desc
+	crlValidity *prometheus.Desc
+
+	mu    sync.Mutex
+	cache map[crlKey]*cachedCRL
+}
+
+// CRLs are only verified against the issuer they were fetched for, so issuers sharing
+// a distribution point url don't get each other's CRLs unchecked
+type crlKey struct {
+	url    string
+	issuer string // SPKI hash
+}
+
+type cachedCRL struct {
+	nextUpdate time.Time
+	revoked    map[string]bool // Serial numbers
+}
+
+func (r *crlChecker) describe(ch chan<- *prometheus.Desc) {
+	ch <- r.revoked
+	ch <- r.crlValidity
+}
+
+// CRLs are shared by many certificates, their validity is only exported once per scrape
+func (r *crlChecker) collect(ctx context.Context, ch chan<- prometheus.Metric, certs []*certificate) {
+	exported := make(map[string]bool)
+
+	for _, cert := range certs {
+		if cert.x509 == nil || len(cert.x509.CRLDistributionPoints) == 0 {
+			continue
+		}
+		issuer := findIssuer(cert.x509, cert.chain)
+		if issuer == nil {
+			log.Debugf("Skipping CRL for certificate [%s] in project [%s] as its issuer wasn't uploaded", cert.name, cert.project)
+			continue
+		}
+
+		revoked := false
+		checked := false
+		for _, url := range cert.x509.CRLDistributionPoints {
+			crl, err := r.get(ctx, url, issuer)
+			if err != nil {
+				log.Errorf("Trying to get CRL [%s] of certificate [%s] in project [%s] with error [%s]", url, cert.name, cert.project, err)
+				continue
+			}
+			checked = true
+			revoked = revoked || crl.revoked[cert.x509.SerialNumber.String()]
+
+			if !exported[url] {
+				exported[url] = true
+				ch <- prometheus.MustNewConstMetric(r.crlValidity, prometheus.GaugeValue,
+					float64(crl.nextUpdate.Unix()-time.Now().Unix()), url)
+			}
+		}
+
+		if checked {
+			ch <- prometheus.MustNewConstMetric(r.revoked, prometheus.GaugeValue, boolToFloat(revoked),
+				cert.name, cert.project, cert.service)
+		}
+	}
+}
+
+// CRLs are cached per issuer until their next update
+func (r *crlChecker) get(ctx context.Context, url string, issuer *x509.Certificate) (*cachedCRL, error) {
+	key := crlKey{url: url, issuer: spkiHash(issuer)}
+	r.mu.Lock()
+	crl, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok && time.Now().Before(crl.nextUpdate) {
+		return crl, nil
+	}
+
+	list, err := r.fetch(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if err := issuer.CheckCRLSignature(list); err != nil {
+		return nil, err
+	}
+
+	crl = &cachedCRL{
+		nextUpdate: list.TBSCertList.NextUpdate,
+		revoked:    make(map[string]bool),
+	}
+	for _, revoked := range list.TBSCertList.RevokedCertificates {
+		crl.revoked[revoked.SerialNumber.String()] = true
+	}
+
+	r.mu.Lock()
+	r.cache[key] = crl
+	r.mu.Unlock()
+	return crl, nil
+}
+
+func (r *crlChecker) fetch(ctx context.Context, url string) (*pkix.CertificateList, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := r.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		e := fmt.Sprintf("CRL distribution point answered with status [%d]", res.StatusCode)
+		return nil, errors.New(e)
+	}
+
+	// Read one byte over the limit to tell whether it was exceeded
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, r.maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > r.maxBytes {
+		e := fmt.Sprintf("CRL is bigger than [%d] bytes", r.maxBytes)
+		return nil, errors.New(e)
+	}
+	return x509.ParseCRL(body)
+}
@@ -0,0 +1,123 @@
+package collector
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestCRLCollect(t *testing.T) {
+	issuer, issuerKey := newTestCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}, nil, nil)
+
+	// CRLs are downloaded through the proxy of GCP requests
+	var requests int32
+	var crl []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "crl.example.invalid" {
+			t.Errorf("Wrong proxied host %s", r.Host)
+		}
+		atomic.AddInt32(&requests, 1)
+		w.Write(crl)
+	}))
+	defer ts.Close()
+
+	transport, err := NewTransport(ts.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newLeaf := func(cn string, issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey) *certificate {
+		leaf, _ := newTestCertificate(t, &x509.Certificate{
+			Subject:               pkix.Name{CommonName: cn},
+			CRLDistributionPoints: []string{"http://crl.example.invalid/ca.crl"},
+		}, issuer, issuerKey)
+		return &certificate{name: cn, project: "project-1", service: "compute", x509: leaf, chain: []*x509.Certificate{issuer}}
+	}
+	good := newLeaf("good.example.com", issuer, issuerKey)
+	bad := newLeaf("revoked.example.com", issuer, issuerKey)
+
+	nextUpdate := time.Now().Add(time.Hour)
+	crl, err = issuer.CreateCRL(rand.Reader, issuerKey, []pkix.RevokedCertificate{
+		{SerialNumber: bad.x509.SerialNumber, RevocationTime: time.Now().Add(-time.Hour)},
+	}, time.Now().Add(-time.Hour), nextUpdate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collect := func(c *SSLCollector, certs ...*certificate) (map[string]float64, []float64) {
+		ch := make(chan prometheus.Metric, 10)
+		c.crl.collect(context.Background(), ch, certs)
+		close(ch)
+
+		revoked := make(map[string]float64)
+		var validities []float64
+		for m := range ch {
+			pb := &dto.Metric{}
+			if err := m.Write(pb); err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(m.Desc().String(), "gcp_ssl_crl_validity_seconds") {
+				validities = append(validities, pb.GetGauge().GetValue())
+				continue
+			}
+			for _, l := range pb.GetLabel() {
+				if l.GetName() == "name" {
+					revoked[l.GetValue()] = pb.GetGauge().GetValue()
+				}
+			}
+		}
+		return revoked, validities
+	}
+
+	c := NewSSLCollector(nil, nil, false, WithCRL(true, time.Second, 1<<20, transport))
+	for i := 0; i < 2; i++ {
+		revoked, validities := collect(c, good, bad)
+		if revoked["good.example.com"] != 0 || revoked["revoked.example.com"] != 1 || len(revoked) != 2 {
+			t.Errorf("Wrong CRL revocations %v", revoked)
+		}
+		// Exported once for both certificates
+		if len(validities) != 1 || validities[0] <= 0 || validities[0] > time.Hour.Seconds() {
+			t.Errorf("Wrong CRL validities %v", validities)
+		}
+	}
+	// Second round is answered from the cache
+	if atomic.LoadInt32(&requests) != 1 {
+		t.Errorf("Wrong number of CRL requests %d", requests)
+	}
+
+	// The cached CRL isn't served for another issuer with the same distribution point
+	other, otherKey := newTestCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}, nil, nil)
+	if revoked, _ := collect(c, newLeaf("other.example.com", other, otherKey)); len(revoked) != 0 {
+		t.Errorf("Expected CRL of another issuer to be refused, got %v", revoked)
+	}
+	if atomic.LoadInt32(&requests) != 2 {
+		t.Errorf("Wrong number of CRL requests %d", requests)
+	}
+
+	// CRLs over the limit are refused
+	c = NewSSLCollector(nil, nil, false, WithCRL(true, time.Second, int64(len(crl)-1), transport))
+	if revoked, validities := collect(c, good, bad); len(revoked) != 0 || len(validities) != 0 {
+		t.Errorf("Expected oversized CRL to be refused, got %v %v", revoked, validities)
+	}
+}
@@ -0,0 +1,123 @@
+package collector
+
+import (
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snebel29/prometheus-gcp-ssl-exporter/internal/pkg/cli"
+)
+
+// Default weak choices, those of the flags
+var (
+	DefaultMinRSABits              = cli.DefaultMinRSABits
+	DefaultMinECDSABits            = cli.DefaultMinECDSABits
+	DefaultWeakSignatureAlgorithms = cli.DefaultWeakSignatureAlgorithms
+	DefaultWeakPublicKeyAlgorithms = cli.DefaultWeakPublicKeyAlgorithms
+)
+
+// WithWeakCrypto sets which public keys and signature algorithms are flagged as weak
+func WithWeakCrypto(minRSABits, minECDSABits int, signatureAlgorithms, keyAlgorithms []string) Option {
+	return func(c *SSLCollector) {
+		c.crypto.minRSABits = minRSABits
+		c.crypto.minECDSABits = minECDSABits
+		c.crypto.weakSignatureAlgorithms = toSet(signatureAlgorithms)
+		c.crypto.weakKeyAlgorithms = toSet(keyAlgorithms)
+	}
+}
+
+type cryptoStrength struct {
+	minRSABits              int
+	minECDSABits            int
+	weakSignatureAlgorithms map[string]bool
+	weakKeyAlgorithms       map[string]bool
+
+	publicKeyBits *prometheus.Desc
+	cryptoInfo    *prometheus.Desc
+	weakCrypto    *prometheus.Desc
+}
+
+func newCryptoStrength(variableLabels []string) *cryptoStrength {
+	return &cryptoStrength{
+		minRSABits:              DefaultMinRSABits,
+		minECDSABits:            DefaultMinECDSABits,
+		weakSignatureAlgorithms: toSet(DefaultWeakSignatureAlgorithms),
+		weakKeyAlgorithms:       toSet(DefaultWeakPublicKeyAlgorithms),
+		publicKeyBits: prometheus.NewDesc("gcp_ssl_public_key_bits",
+			"Size of an ssl certificate public key, RSA modulus or ECDSA curve size",
+			variableLabels, nil),
+		cryptoInfo: prometheus.NewDesc("gcp_ssl_crypto_info",
+			"Public key algorithm, ECDSA curve and signature algorithm of an ssl certificate",
+			append(variableLabels, "key_algorithm", "curve", "signature_algorithm"), nil),
+		weakCrypto: prometheus.NewDesc("gcp_ssl_weak_crypto",
+			"Whether an ssl certificate key algorithm, key size or signature algorithm is weak",
+			append(variableLabels, "reason"), nil),
+	}
+}
+
+func (s *cryptoStrength) describe(ch chan<- *prometheus.Desc) {
+	ch <- s.publicKeyBits
+	ch <- s.cryptoInfo
+	ch <- s.weakCrypto
+}
+
+func (s *cryptoStrength) collect(ch chan<- prometheus.Metric, cert *certificate) {
+	if cert.x509 == nil {
+		return
+	}
+	labels := []string{cert.name, cert.project, cert.service}
+	keyAlgorithm := cert.x509.PublicKeyAlgorithm.String()
+	signatureAlgorithm := cert.x509.SignatureAlgorithm.String()
+	bits, curve := publicKeySize(cert.x509.PublicKey)
+
+	weakKeySize := false
+	switch cert.x509.PublicKeyAlgorithm {
+	case x509.RSA:
+		weakKeySize = bits < s.minRSABits
+	case x509.ECDSA:
+		weakKeySize = bits < s.minECDSABits
+	}
+
+	ch <- prometheus.MustNewConstMetric(s.publicKeyBits, prometheus.GaugeValue, float64(bits), labels...)
+	ch <- prometheus.MustNewConstMetric(s.cryptoInfo, prometheus.GaugeValue, 1,
+		append(labels, keyAlgorithm, curve, signatureAlgorithm)...)
+
+	for reason, weak := range map[string]bool{
+		"key_algorithm":       s.weakKeyAlgorithms[keyAlgorithm],
+		"key_size":            weakKeySize,
+		"signature_algorithm": s.weakSignatureAlgorithms[signatureAlgorithm],
+	} {
+		ch <- prometheus.MustNewConstMetric(s.weakCrypto, prometheus.GaugeValue, boolToFloat(weak),
+			append(labels, reason)...)
+	}
+}
+
+// Returns the public key size in bits along the curve name for ECDSA keys
+func publicKeySize(key interface{}) (int, string) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return k.N.BitLen(), ""
+	case *ecdsa.PublicKey:
+		return k.Curve.Params().BitSize, k.Curve.Params().Name
+	case *dsa.PublicKey:
+		return k.P.BitLen(), ""
+	}
+	return 0, ""
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1.0
+	}
+	return 0.0
+}
+
+func toSet(items []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestCryptoStrengthCollect(t *testing.T) {
+	c, err := parseCertificate(pemData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &certificate{name: "mail", project: "project-1", service: "compute", x509: c}
+
+	for _, tc := range []struct {
+		opts []Option
+		weak map[string]float64
+	}{
+		{nil, map[string]float64{"key_algorithm": 0, "key_size": 0, "signature_algorithm": 1}},
+		{[]Option{WithWeakCrypto(2048, 384, nil, []string{"ECDSA"})},
+			map[string]float64{"key_algorithm": 1, "key_size": 1, "signature_algorithm": 0}},
+	} {
+		collector := NewSSLCollector(nil, nil, false, tc.opts...)
+		ch := make(chan prometheus.Metric, 10)
+		collector.crypto.collect(ch, cert)
+		close(ch)
+
+		weak := make(map[string]float64)
+		for m := range ch {
+			pb := &dto.Metric{}
+			if err := m.Write(pb); err != nil {
+				t.Fatal(err)
+			}
+			labels := make(map[string]string)
+			for _, l := range pb.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+
+			desc := m.Desc().String()
+			switch {
+			case strings.Contains(desc, "gcp_ssl_public_key_bits"):
+				if pb.GetGauge().GetValue() != 256 {
+					t.Errorf("Wrong public key bits %v", pb.GetGauge().GetValue())
+				}
+			case strings.Contains(desc, "gcp_ssl_crypto_info"):
+				if labels["key_algorithm"] != "ECDSA" || labels["curve"] != "P-256" || labels["signature_algorithm"] != "SHA1-RSA" {
+					t.Errorf("Wrong crypto info %v", labels)
+				}
+			case strings.Contains(desc, "gcp_ssl_weak_crypto"):
+				weak[labels["reason"]] = pb.GetGauge().GetValue()
+			}
+		}
+		for reason, value := range tc.weak {
+			if weak[reason] != value {
+				t.Errorf("Weak %s should be %v, got %v", reason, value, weak)
+			}
+		}
+	}
+}
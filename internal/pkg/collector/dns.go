@@ -0,0 +1,190 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/api/dns/v1"
+)
+
+// CNAME chains longer than this are considered loops
+const maxCNAMEHops = 8
+
+// WithDNSBindings enables matching A, AAAA and CNAME records within the Cloud DNS managed zones
+// against the forwarding rules in front of HTTPS and SSL proxies
+func WithDNSBindings(enabled bool) Option {
+	return func(c *SSLCollector) {
+		if !enabled {
+			return
+		}
+		c.dnsBinder = &dnsBinder{
+			bindingInfo: prometheus.NewDesc("gcp_ssl_dns_binding_info",
+				"Certificate served for a hostname whose DNS record points at a load balancer frontend",
+				[]string{"hostname", "zone", "certificate", "project"}, nil),
+			hostnameUncovered: prometheus.NewDesc("gcp_ssl_dns_hostname_uncovered",
+				"Hostname whose DNS record points at a load balancer frontend not covered by any of its certificates",
+				[]string{"hostname", "zone", "proxy", "project"}, nil),
+		}
+	}
+}
+
+type dnsBinder struct {
+	bindingInfo       *prometheus.Desc
+	hostnameUncovered *prometheus.Desc
+}
+
+func (b *dnsBinder) describe(ch chan<- *prometheus.Desc) {
+	ch <- b.bindingInfo
+	ch <- b.hostnameUncovered
+}
+
+type dnsRecord struct {
+	name    string // Without the trailing dot
+	zone    string
+	kind    string
+	rrdatas []string
+}
+
+type dnsBinding struct {
+	hostname string
+	zone     string
+	frontend *frontend
+}
+
+// Records are listed on their own, so those of projects failing frontend discovery still bind
+// to the load balancers of any other
+func (c *SSLCollector) collectDNSBindings(ctx context.Context, ch chan<- prometheus.Metric, frontends []*frontend) {
+	svc, err := c.newDNSService()
+	if err != nil {
+		log.Errorf("Trying to instantiate dns service: [%s]", err)
+		return
+	}
+
+	var records []*dnsRecord
+	for _, project := range c.projects {
+		r, err := listDNSRecords(ctx, svc, project)
+		if err != nil {
+			log.Errorf("%s", err)
+			continue
+		}
+		records = append(records, r...)
+	}
+
+	c.dnsBinder.collect(ch, dnsBindings(records, frontends))
+}
+
+func (b *dnsBinder) collect(ch chan<- prometheus.Metric, bindings []*dnsBinding) {
+	// Frontends listening on several ports of the same address would produce duplicates
+	seen := make(map[string]bool)
+	emit := func(desc *prometheus.Desc, labels ...string) {
+		key := desc.String() + strings.Join(labels, "\xff")
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, labels...)
+	}
+
+	for _, binding := range bindings {
+		f := binding.frontend
+		for _, name := range f.certificateNames {
+			emit(b.bindingInfo, binding.hostname, binding.zone, name, f.project)
+		}
+		if !certificatesCover(f.certificates, binding.hostname) {
+			emit(b.hostnameUncovered, binding.hostname, binding.zone, f.proxy, f.project)
+		}
+	}
+}
+
+func listDNSRecords(ctx context.Context, svc *dns.Service, project string) ([]*dnsRecord, error) {
+	var records []*dnsRecord
+	var zones []*dns.ManagedZone
+	err := svc.ManagedZones.List(project).Pages(ctx, func(res *dns.ManagedZonesListResponse) error {
+		zones = append(zones, res.ManagedZones...)
+		return nil
+	})
+	if err != nil {
+		e := fmt.Sprintf("Trying to list managed zones in project [%s] with error [%s]", project, err)
+		return nil, errors.New(e)
+	}
+
+	for _, zone := range zones {
+		err := svc.ResourceRecordSets.List(project, zone.Name).Pages(ctx, func(res *dns.ResourceRecordSetsListResponse) error {
+			for _, rrset := range res.Rrsets {
+				if rrset.Type != "A" && rrset.Type != "AAAA" && rrset.Type != "CNAME" {
+					continue
+				}
+				records = append(records, &dnsRecord{
+					name:    strings.TrimSuffix(rrset.Name, "."),
+					zone:    zone.Name,
+					kind:    rrset.Type,
+					rrdatas: rrset.Rrdatas,
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			e := fmt.Sprintf("Trying to list records of zone [%s] in project [%s] with error [%s]", zone.Name, project, err)
+			return nil, errors.New(e)
+		}
+	}
+	return records, nil
+}
+
+// Match the addresses every record resolves to against the frontends, CNAMEs are followed
+// through the records of the managed zones only
+func dnsBindings(records []*dnsRecord, frontends []*frontend) []*dnsBinding {
+	byName := make(map[string][]*dnsRecord)
+	for _, r := range records {
+		byName[strings.ToLower(r.name)] = append(byName[strings.ToLower(r.name)], r)
+	}
+
+	byIP := make(map[string][]*frontend)
+	for _, f := range frontends {
+		host, _, err := net.SplitHostPort(f.address)
+		if err != nil {
+			continue
+		}
+		byIP[normalizeIP(host)] = append(byIP[normalizeIP(host)], f)
+	}
+
+	var bindings []*dnsBinding
+	for _, r := range records {
+		for _, ip := range resolveDNSRecord(r, byName, 0) {
+			for _, f := range byIP[normalizeIP(ip)] {
+				bindings = append(bindings, &dnsBinding{hostname: r.name, zone: r.zone, frontend: f})
+			}
+		}
+	}
+	return bindings
+}
+
+// IPv6 addresses may be written in several ways
+func normalizeIP(s string) string {
+	if ip := net.ParseIP(s); ip != nil {
+		return ip.String()
+	}
+	return s
+}
+
+func resolveDNSRecord(r *dnsRecord, byName map[string][]*dnsRecord, hops int) []string {
+	if r.kind != "CNAME" {
+		return r.rrdatas
+	}
+	if hops >= maxCNAMEHops {
+		return nil
+	}
+
+	var ips []string
+	for _, target := range r.rrdatas {
+		for _, next := range byName[strings.ToLower(strings.TrimSuffix(target, "."))] {
+			ips = append(ips, resolveDNSRecord(next, byName, hops+1)...)
+		}
+	}
+	return ips
+}
@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/dns/v1"
+)
+
+func TestDNSBindings(t *testing.T) {
+	mail, err := parseCertificate(pemData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frontends := []*frontend{
+		{project: "project-1", proxy: "mail-proxy", address: "35.1.2.3:443",
+			certificates: []*x509.Certificate{mail}, certificateNames: []string{"mail"}},
+		{project: "project-1", proxy: "mail-proxy", address: "35.1.2.3:8443",
+			certificates: []*x509.Certificate{mail}, certificateNames: []string{"mail"}},
+		{project: "project-1", proxy: "v6-proxy", address: "[2600:1901:0:0::1]:443",
+			certificates: []*x509.Certificate{mail}, certificateNames: []string{"mail"}},
+	}
+	records := []*dnsRecord{
+		{name: "mail.google.com", zone: "google", kind: "CNAME", rrdatas: []string{"lb.google.com."}},
+		{name: "lb.google.com", zone: "google", kind: "A", rrdatas: []string{"35.1.2.3"}},
+		{name: "lb.google.com", zone: "google", kind: "AAAA", rrdatas: []string{"2600:1901::1"}},
+		{name: "loop.google.com", zone: "google", kind: "CNAME", rrdatas: []string{"loop.google.com."}},
+		{name: "other.google.com", zone: "google", kind: "A", rrdatas: []string{"35.9.9.9"}},
+	}
+
+	bindings := dnsBindings(records, frontends)
+	hostnames := make(map[string]int)
+	for _, b := range bindings {
+		hostnames[b.hostname]++
+	}
+	if len(bindings) != 6 || hostnames["mail.google.com"] != 3 || hostnames["lb.google.com"] != 3 {
+		t.Fatalf("Wrong bindings %v", hostnames)
+	}
+
+	c := NewSSLCollector(nil, nil, false, WithDNSBindings(true))
+	ch := make(chan prometheus.Metric, 20)
+	c.dnsBinder.collect(ch, bindings)
+	close(ch)
+
+	// One binding info per hostname and one uncovered lb.google.com per proxy
+	if len(ch) != 4 {
+		t.Errorf("Wrong number of metrics %d", len(ch))
+	}
+}
+
+func TestDNSBindingsDiscoverFrontendsOnce(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	failing := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/compute/v1/projects/project-1/global/targetHttpsProxies":
+			if failing {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			json.NewEncoder(w).Encode(compute.TargetHttpsProxyList{})
+		case "/compute/v1/projects/project-1/global/targetSslProxies":
+			json.NewEncoder(w).Encode(compute.TargetSslProxyList{})
+		case "/compute/v1/projects/project-1/global/forwardingRules":
+			json.NewEncoder(w).Encode(compute.ForwardingRuleList{})
+		case "/dns/v1/projects/project-1/managedZones":
+			json.NewEncoder(w).Encode(dns.ManagedZonesListResponse{ManagedZones: []*dns.ManagedZone{{Name: "google"}}})
+		case "/dns/v1/projects/project-1/managedZones/google/rrsets":
+			json.NewEncoder(w).Encode(dns.ResourceRecordSetsListResponse{})
+		default:
+			t.Errorf("Unexpected request path %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	endpoints, err := ParseAPIEndpoints(map[string]string{"compute": ts.URL + "/compute/v1", "dns": ts.URL + "/dns/v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewSSLCollector([]string{"project-1"}, ts.Client(), false,
+		WithProbe(true, time.Second), WithDNSBindings(true), WithAPIEndpoints(endpoints))
+	collect := func() {
+		ch := make(chan prometheus.Metric, 20)
+		c.collectLive(context.Background(), ch, nil)
+		close(ch)
+	}
+
+	// Shared between the probe and DNS bindings
+	collect()
+	if requests["/compute/v1/projects/project-1/global/forwardingRules"] != 1 {
+		t.Errorf("Expected frontends to be discovered once, got %v", requests)
+	}
+
+	// Records are listed even though frontend discovery fails
+	failing = true
+	collect()
+	if requests["/dns/v1/projects/project-1/managedZones/google/rrsets"] != 2 {
+		t.Errorf("Expected DNS records to be listed despite the discovery failure, got %v", requests)
+	}
+}
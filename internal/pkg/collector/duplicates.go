@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type duplicateDetector struct {
+	fingerprintInfo *prometheus.Desc
+	duplicateCount  *prometheus.Desc
+	publicKeyShared *prometheus.Desc
+}
+
+func newDuplicateDetector(variableLabels []string) *duplicateDetector {
+	return &duplicateDetector{
+		fingerprintInfo: prometheus.NewDesc("gcp_ssl_fingerprint_info",
+			"SHA-256 fingerprint and SubjectPublicKeyInfo hash of an ssl certificate",
+			append(variableLabels, "fingerprint", "spki_hash"), nil),
+		duplicateCount: prometheus.NewDesc("gcp_ssl_duplicate_count",
+			"Number of copies of an ssl certificate across every project and service",
+			[]string{"fingerprint"}, nil),
+		publicKeyShared: prometheus.NewDesc("gcp_ssl_public_key_shared",
+			"Number of distinct ssl certificates sharing a public key",
+			[]string{"spki_hash"}, nil),
+	}
+}
+
+func (d *duplicateDetector) describe(ch chan<- *prometheus.Desc) {
+	ch <- d.fingerprintInfo
+	ch <- d.duplicateCount
+	ch <- d.publicKeyShared
+}
+
+// Copies of a certificate share its key as well, so keys are counted once per distinct certificate
+func (d *duplicateDetector) collect(ch chan<- prometheus.Metric, certs []*certificate) {
+	copies := make(map[string]int)
+	keys := make(map[string]map[string]bool)
+
+	for _, cert := range certs {
+		if cert.x509 == nil {
+			continue
+		}
+		f := fingerprint(cert.x509)
+		k := spkiHash(cert.x509)
+		copies[f]++
+		if keys[k] == nil {
+			keys[k] = make(map[string]bool)
+		}
+		keys[k][f] = true
+
+		ch <- prometheus.MustNewConstMetric(d.fingerprintInfo, prometheus.GaugeValue, 1,
+			cert.name, cert.project, cert.service, f, k)
+	}
+
+	for f, n := range copies {
+		ch <- prometheus.MustNewConstMetric(d.duplicateCount, prometheus.GaugeValue, float64(n), f)
+	}
+	for k, fingerprints := range keys {
+		ch <- prometheus.MustNewConstMetric(d.publicKeyShared, prometheus.GaugeValue, float64(len(fingerprints)), k)
+	}
+}
+
+// SHA-256 hash of the DER encoded SubjectPublicKeyInfo
+func spkiHash(c *x509.Certificate) string {
+	sum := sha256.Sum256(c.RawSubjectPublicKeyInfo)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,83 @@
+package collector
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestDuplicateDetectorCollect(t *testing.T) {
+	original, err := parseCertificate(pemData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A renewal reusing the private key of another certificate
+	_, _, leaves := newTestChain(t, "www.example.com")
+	root, rootKey := newTestCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test Root"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "www.example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}, root, leaves[0].PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renewal, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certs := []*certificate{
+		{name: "cert-1", project: "project-1", service: "compute", x509: original},
+		{name: "copy-of-cert-1", project: "project-2", service: "compute", x509: original},
+		{name: "cert-2", project: "project-1", service: "compute", x509: leaves[0]},
+		{name: "cert-2-renewal", project: "project-1", service: "compute", x509: renewal},
+		{name: "unparsed", project: "project-1", service: "compute"},
+	}
+
+	c := NewSSLCollector(nil, nil, false)
+	ch := make(chan prometheus.Metric, 20)
+	c.duplicates.collect(ch, certs)
+	close(ch)
+
+	infos := 0
+	duplicates := make(map[string]float64)
+	shared := make(map[string]float64)
+	for m := range ch {
+		pb := &dto.Metric{}
+		if err := m.Write(pb); err != nil {
+			t.Fatal(err)
+		}
+		switch m.Desc() {
+		case c.duplicates.fingerprintInfo:
+			infos++
+		case c.duplicates.duplicateCount:
+			duplicates[pb.GetLabel()[0].GetValue()] = pb.GetGauge().GetValue()
+		case c.duplicates.publicKeyShared:
+			shared[pb.GetLabel()[0].GetValue()] = pb.GetGauge().GetValue()
+		}
+	}
+
+	if infos != 4 {
+		t.Errorf("Wrong number of fingerprint infos %d", infos)
+	}
+	if len(duplicates) != 3 || duplicates[fingerprint(original)] != 2 || duplicates[fingerprint(renewal)] != 1 {
+		t.Errorf("Wrong duplicate counts %v", duplicates)
+	}
+	if len(shared) != 2 || shared[spkiHash(original)] != 1 || shared[spkiHash(renewal)] != 2 {
+		t.Errorf("Wrong shared public keys %v", shared)
+	}
+}
@@ -0,0 +1,164 @@
+package collector
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/dns/v1"
+	"google.golang.org/api/sqladmin/v1beta4"
+)
+
+// Default base path of the APIs without a generated client, by the name --api-endpoint overrides it with
+var apiBasePaths = map[string]*string{
+	"redis":                &redisBasePath,
+	"alloydb":              &alloydbBasePath,
+	"iam":                  &iamBasePath,
+	"cloudresourcemanager": &resourceManagerBasePath,
+	"iamcredentials":       &iamCredentialsBasePath,
+}
+
+// Root URL of the APIs with a generated client, whose base paths include the projects collection for compute and dns
+var generatedAPIRoots = map[string]string{
+	"compute":  "https://www.googleapis.com/compute/v1/",
+	"sqladmin": "https://www.googleapis.com/sql/v1beta4/",
+	"dns":      "https://www.googleapis.com/dns/v1/",
+}
+
+// ParseAPIEndpoints validates the base URLs of the APIs in endpoints, such as
+// https://compute-myendpoint.p.googleapis.com/compute/v1/ for a Private Service Connect endpoint,
+// and returns them ending with a slash
+func ParseAPIEndpoints(endpoints map[string]string) (map[string]string, error) {
+	parsed := make(map[string]string)
+	for api, endpoint := range endpoints {
+		_, ok := apiBasePaths[api]
+		if _, generated := generatedAPIRoots[api]; !ok && !generated {
+			var apis []string
+			for name := range apiBasePaths {
+				apis = append(apis, name)
+			}
+			for name := range generatedAPIRoots {
+				apis = append(apis, name)
+			}
+			sort.Strings(apis)
+			e := fmt.Sprintf("Unknown API [%s], expected one of [%s]", api, strings.Join(apis, ", "))
+			return nil, errors.New(e)
+		}
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			e := fmt.Sprintf("Invalid endpoint [%s] of API [%s]", endpoint, api)
+			return nil, errors.New(e)
+		}
+		if !strings.HasSuffix(endpoint, "/") {
+			endpoint += "/"
+		}
+		parsed[api] = endpoint
+	}
+	return parsed, nil
+}
+
+// WithAPIEndpoints overrides the base URL of the APIs in endpoints, as returned by ParseAPIEndpoints
+func WithAPIEndpoints(endpoints map[string]string) Option {
+	return func(c *SSLCollector) {
+		c.endpoints = endpoints
+	}
+}
+
+// Returns the base URL of an API without a generated client
+func (c *SSLCollector) apiBasePath(api string) string {
+	if endpoint, ok := c.endpoints[api]; ok {
+		return endpoint
+	}
+	return *apiBasePaths[api]
+}
+
+// The vendored compute and dns clients expect their base path to include the projects collection
+func (c *SSLCollector) newComputeService() (*compute.Service, error) {
+	svc, err := compute.New(c.httpClient)
+	if endpoint, ok := c.endpoints["compute"]; err == nil && ok {
+		svc.BasePath = endpoint + "projects/"
+	}
+	return svc, err
+}
+
+func (c *SSLCollector) newSQLAdminService() (*sqladmin.Service, error) {
+	svc, err := sqladmin.New(c.httpClient)
+	if endpoint, ok := c.endpoints["sqladmin"]; err == nil && ok {
+		svc.BasePath = endpoint
+	}
+	return svc, err
+}
+
+func (c *SSLCollector) newDNSService() (*dns.Service, error) {
+	svc, err := dns.New(c.httpClient)
+	if endpoint, ok := c.endpoints["dns"]; err == nil && ok {
+		svc.BasePath = endpoint + "projects/"
+	}
+	return svc, err
+}
+
+// NewTransport returns the transport of every request to GCP, through proxyURL instead of the one
+// within the environment when given, trusting the CA certificates of the PEM bundle in caFile besides the system ones
+func NewTransport(proxyURL, caFile string) (*http.Transport, error) {
+	// Same settings as http.DefaultTransport
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if proxyURL != "" {
+		u, err := url.Parse(proxyURL)
+		if err != nil || u.Host == "" {
+			e := fmt.Sprintf("Invalid proxy URL [%s]", proxyURL)
+			return nil, errors.New(e)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+	if caFile != "" {
+		data, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			e := fmt.Sprintf("No PEM certificate found within CA bundle [%s]", caFile)
+			return nil, errors.New(e)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return transport, nil
+}
+
+// Returns the root URL of every API by name, as overridden by endpoints. Unlike their base
+// paths those of the generated clients don't include the projects collection
+func apiRoots(endpoints map[string]string) map[string]string {
+	roots := make(map[string]string)
+	for api, root := range generatedAPIRoots {
+		roots[api] = root
+	}
+	for api, basePath := range apiBasePaths {
+		roots[api] = *basePath
+	}
+	for api, endpoint := range endpoints {
+		roots[api] = endpoint
+	}
+	return roots
+}
@@ -0,0 +1,110 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/compute/v1"
+)
+
+func TestWithAPIEndpoints(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/compute/v1/projects/project-1/global/sslCertificates" {
+			t.Errorf("Unexpected request path %s", r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(compute.SslCertificateList{
+			Items: []*compute.SslCertificate{{Name: "web", Certificate: pemData}},
+		})
+	}))
+	defer ts.Close()
+
+	endpoints, err := ParseAPIEndpoints(map[string]string{"compute": ts.URL + "/compute/v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewSSLCollector([]string{"project-1"}, ts.Client(), false, WithServices([]string{"compute"}), WithAPIEndpoints(endpoints))
+	certs, err := c.fetchFromGCP(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 1 || certs[0].name != "web" {
+		t.Errorf("Wrong certificates fetched %v", certs)
+	}
+}
+
+func TestParseAPIEndpointsInvalid(t *testing.T) {
+	tests := []struct {
+		endpoints map[string]string
+		err       string
+	}{
+		{map[string]string{"unknown": "https://example.com/"}, "Unknown API [unknown]"},
+		{map[string]string{"redis": "redis.googleapis.com"}, "Invalid endpoint [redis.googleapis.com] of API [redis]"},
+	}
+
+	for _, test := range tests {
+		if _, err := ParseAPIEndpoints(test.endpoints); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Expected error [%s], got [%v]", test.err, err)
+		}
+	}
+}
+
+func TestNewTransportProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host != "compute.googleapis.com" {
+			t.Errorf("Unexpected proxied request %s", r.URL)
+		}
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer proxy.Close()
+
+	transport, err := NewTransport(proxy.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := (&http.Client{Transport: transport}).Get("http://compute.googleapis.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusTeapot {
+		t.Errorf("Request didn't go through the proxy, got %d", res.StatusCode)
+	}
+}
+
+func TestNewTransportCABundle(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	f, err := ioutil.TempFile("", "ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	pem.Encode(f, &pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	f.Close()
+
+	for bundle, trusted := range map[string]bool{"": false, f.Name(): true} {
+		transport, err := NewTransport("", bundle)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := (&http.Client{Transport: transport}).Get(ts.URL)
+		if err == nil {
+			res.Body.Close()
+		}
+		if (err == nil) != trusted {
+			t.Errorf("Wrong trust with CA bundle [%s], got error [%v]", bundle, err)
+		}
+	}
+
+	if _, err := NewTransport("", os.DevNull); err == nil || !strings.Contains(err.Error(), "No PEM certificate found") {
+		t.Errorf("Expected bundles without certificates to be refused, got [%v]", err)
+	}
+}
@@ -0,0 +1,179 @@
+package collector
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/pkcs12"
+)
+
+// WithFileGlobs sets glob patterns of local PEM, DER or PKCS#12 certificate files to fetch certificates from
+func WithFileGlobs(globs []string, pkcs12Password string) Option {
+	return func(c *SSLCollector) {
+		if len(globs) == 0 {
+			return
+		}
+		c.files = &fileSource{
+			globs:          globs,
+			pkcs12Password: pkcs12Password,
+			cache:          make(map[string]*cachedFile),
+		}
+	}
+}
+
+type fileSource struct {
+	globs          []string
+	pkcs12Password string
+
+	mu    sync.Mutex
+	cache map[string]*cachedFile
+}
+
+// Files are only read again when their size or modification time change
+type cachedFile struct {
+	modTime time.Time
+	size    int64
+	certs   []*gcpCertificate
+}
+
+// Fetch certificates from local files matching any of the globs, which are evaluated on every call
+func (c *SSLCollector) fetchFromFiles() ([]*certificate, error) {
+	if c.files == nil {
+		return nil, nil
+	}
+	s := c.files
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var certs []*certificate
+
+	for _, glob := range s.globs {
+		paths, err := filepath.Glob(glob)
+		if err != nil {
+			e := fmt.Sprintf("Trying to match file glob [%s] with error [%s]", glob, err)
+			return nil, errors.New(e)
+		}
+
+		for _, path := range paths {
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+
+			gcpCerts, err := s.read(path)
+			if err != nil {
+				e := fmt.Sprintf("Trying to read certificates from file [%s] with error [%s]", path, err)
+				return nil, errors.New(e)
+			}
+			fileCerts, err := toInternalCertificates(gcpCerts, "")
+			if err != nil {
+				return nil, err
+			}
+			certs = append(certs, fileCerts...)
+		}
+	}
+
+	// Forget files which no longer match
+	for path := range s.cache {
+		if !seen[path] {
+			delete(s.cache, path)
+		}
+	}
+	return certs, nil
+}
+
+func (s *fileSource) read(path string) ([]*gcpCertificate, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, nil
+	}
+	if cached, ok := s.cache[path]; ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.certs, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	blocks, err := decodeCertificateFile(data, s.pkcs12Password)
+	if err != nil {
+		return nil, err
+	}
+
+	var gcpCerts []*gcpCertificate
+	for i, block := range blocks {
+		name := path
+		if len(blocks) > 1 {
+			name = fmt.Sprintf("%s-%d", path, i)
+		}
+		gcpCerts = append(gcpCerts, &gcpCertificate{
+			name:     name,
+			raw:      string(pem.EncodeToMemory(block)),
+			service:  "file",
+			instance: path,
+		})
+	}
+
+	s.cache[path] = &cachedFile{modTime: info.ModTime(), size: info.Size(), certs: gcpCerts}
+	return gcpCerts, nil
+}
+
+// Returns one PEM block per certificate found within PEM, DER or PKCS#12 encoded data, so
+// every certificate of a bundle goes through parseCertificate on its own
+func decodeCertificateFile(data []byte, pkcs12Password string) ([]*pem.Block, error) {
+	var blocks []*pem.Block
+
+	if bytes.Contains(data, []byte("-----BEGIN")) {
+		remainder := data
+		for {
+			var block *pem.Block
+			block, remainder = pem.Decode(remainder)
+			if block == nil {
+				break
+			}
+			if block.Type == "CERTIFICATE" {
+				blocks = append(blocks, block)
+			}
+		}
+		if len(blocks) == 0 {
+			return nil, errors.New("PEM not parsed")
+		}
+		return blocks, nil
+	}
+
+	if certs, err := x509.ParseCertificates(data); err == nil {
+		for _, c := range certs {
+			blocks = append(blocks, &pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})
+		}
+		return blocks, nil
+	}
+
+	p12Blocks, err := pkcs12.ToPEM(data, pkcs12Password)
+	if err != nil {
+		// Any error past reading the PFX structure, such as a wrong password, is worth telling
+		if strings.HasPrefix(err.Error(), "pkcs12: error reading P12 data") {
+			return nil, errors.New("Neither PEM, DER nor PKCS#12 could be parsed")
+		}
+		e := fmt.Sprintf("Trying to decode PKCS#12 with error [%s]", err)
+		return nil, errors.New(e)
+	}
+	for _, block := range p12Blocks {
+		if block.Type == "CERTIFICATE" {
+			blocks = append(blocks, &pem.Block{Type: block.Type, Bytes: block.Bytes})
+		}
+	}
+	return blocks, nil
+}
@@ -0,0 +1,75 @@
+package collector
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetchFromFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certificates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	block, _ := pem.Decode([]byte(pemData))
+	if err := ioutil.WriteFile(filepath.Join(dir, "bundle.pem"), []byte(pemData), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "leaf.der"), block.Bytes, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewSSLCollector(nil, nil, false,
+		WithFileGlobs([]string{filepath.Join(dir, "*"), "testdata/*.p12"}, "test"))
+	certs, err := c.fetchFromFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 4 {
+		t.Fatalf("Wrong number of certs, %d should be %d", len(certs), 4)
+	}
+	for _, cert := range certs {
+		if cert.service != "file" {
+			t.Errorf("The following certificate struc is wrong %#v", cert)
+		}
+	}
+	if certs[0].name != filepath.Join(dir, "bundle.pem")+"-0" || certs[2].name != filepath.Join(dir, "leaf.der") {
+		t.Errorf("Wrong certificate names %s %s", certs[0].name, certs[2].name)
+	}
+
+	// Changed files are read again
+	later := time.Now().Add(time.Minute)
+	if err := ioutil.WriteFile(filepath.Join(dir, "bundle.pem"), pem.EncodeToMemory(block), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(filepath.Join(dir, "bundle.pem"), later, later)
+	certs, err = c.fetchFromFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 3 || certs[0].name != filepath.Join(dir, "bundle.pem") {
+		t.Errorf("Changed file was not read again, got %d certs", len(certs))
+	}
+}
+
+func TestDecodeCertificateFileWrongPKCS12Password(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/certificate.p12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = decodeCertificateFile(data, "wrong")
+	if err == nil || !strings.Contains(err.Error(), "decryption password incorrect") {
+		t.Errorf("Expected the PKCS#12 error, got [%v]", err)
+	}
+	// Content that isn't PKCS#12 at all keeps the generic error
+	_, err = decodeCertificateFile([]byte("garbage"), "wrong")
+	if err == nil || err.Error() != "Neither PEM, DER nor PKCS#12 could be parsed" {
+		t.Errorf("Expected the generic error, got [%v]", err)
+	}
+}
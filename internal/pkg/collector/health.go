@@ -0,0 +1,121 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/snebel29/prometheus-gcp-ssl-exporter/internal/pkg/web"
+	"golang.org/x/oauth2"
+)
+
+// How long Warmup waits before trying again
+var warmupInterval = 10 * time.Second
+
+// Whether certificates were ever fetched without error
+type readiness struct {
+	mu    sync.Mutex
+	ready bool
+}
+
+func (r *readiness) set() {
+	r.mu.Lock()
+	r.ready = true
+	r.mu.Unlock()
+}
+
+func (r *readiness) get() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ready
+}
+
+// Ready tells whether credentials work and certificates were fetched once, by a scrape or by Warmup
+func (c *SSLCollector) Ready() bool {
+	return c.readiness.get()
+}
+
+// Warmup fetches certificates until it succeeds once or the collector is stopped, so the collector
+// becomes ready without waiting for a scrape. With a state file it waits for the persisted certificates
+// to be refreshed instead
+func (c *SSLCollector) Warmup() {
+	for !c.Ready() {
+		if err := c.warmup(); err != nil {
+			log.Errorf("Trying to warm up with error [%s], retrying in %s", err, warmupInterval)
+		}
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(warmupInterval):
+		}
+	}
+}
+
+func (c *SSLCollector) warmup() error {
+	// Tokens are checked on their own as there may be no project to fetch from until /probe is scraped
+	clients := append([]*http.Client{c.httpClient}, c.configured.current().clients()...)
+	for _, client := range clients {
+		if t, ok := client.Transport.(*oauth2.Transport); ok {
+			if _, err := t.Source.Token(); err != nil {
+				return err
+			}
+		}
+	}
+	if _, stale := c.state.current(); stale {
+		c.state.refresh(c.refreshFetch, c.rotations)
+		return nil
+	}
+	if _, err := c.fetchConfigured(c.ctx); err != nil {
+		return err
+	}
+	if !c.configured.current().discovered() {
+		return errors.New("Trying to discover projects of every discovery rule with no success yet")
+	}
+	return nil
+}
+
+// Stop cancels Warmup and the background refreshes of the collector
+func (c *SSLCollector) Stop() {
+	c.cancel()
+}
+
+// HealthyHandler answers as long as the process serves requests
+func HealthyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "Healthy")
+	})
+}
+
+// ReadyHandler answers with 503 until the collector is ready
+func ReadyHandler(c *SSLCollector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.Ready() {
+			http.Error(w, "Not ready", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "Ready")
+	})
+}
+
+// Serves until stop receives, then stops accepting connections and gives in-flight scrapes timeout to complete
+func serveUntilTerminated(server *http.Server, webConfigFile string, timeout time.Duration, stop <-chan os.Signal) error {
+	served := make(chan error, 1)
+	go func() {
+		served <- web.ListenAndServe(server, webConfigFile)
+	}()
+	select {
+	case err := <-served:
+		return err
+	case sig := <-stop:
+		log.Infof("Received [%s], waiting up to %s for in-flight scrapes", sig, timeout)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return server.Shutdown(ctx)
+}
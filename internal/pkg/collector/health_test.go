@@ -0,0 +1,187 @@
+package collector
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/snebel29/prometheus-gcp-ssl-exporter/internal/pkg/config"
+)
+
+func TestWarmup(t *testing.T) {
+	var requests int32
+	redis := newRedisTestServer(t)
+	defer redis.Close()
+	// The first attempt fails
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		redis.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	defer func(p string) { redisBasePath = p }(redisBasePath)
+	redisBasePath = ts.URL + "/"
+	defer func(i time.Duration) { warmupInterval = i }(warmupInterval)
+	warmupInterval = 10 * time.Millisecond
+
+	c := NewSSLCollector([]string{"project-1"}, ts.Client(), false, WithServices([]string{"redis"}))
+	ready := httptest.NewServer(ReadyHandler(c))
+	defer ready.Close()
+
+	status := func() int {
+		res, err := http.Get(ready.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+	if status() != http.StatusServiceUnavailable {
+		t.Errorf("Collector shouldn't be ready before fetching certificates")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.Warmup()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Warmup didn't complete")
+	}
+	if status() != http.StatusOK {
+		t.Errorf("Collector should be ready once certificates are fetched")
+	}
+}
+
+func TestReadyAfterDiscovery(t *testing.T) {
+	redis := newRedisTestServer(t)
+	defer redis.Close()
+	defer func(p string) { redisBasePath = p }(redisBasePath)
+	redisBasePath = redis.URL + "/"
+
+	var failing int32 = 1
+	rm := newResourceManagerTestServer(t)
+	defer rm.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&failing) == 1 {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		rm.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	defer func(p string) { resourceManagerBasePath = p }(resourceManagerBasePath)
+	resourceManagerBasePath = ts.URL + "/"
+
+	c := NewSSLCollector(nil, http.DefaultClient, false)
+	cfg := &config.Config{Discovery: []*config.DiscoveryConfig{{
+		Filter:       "labels.env:prod",
+		TargetConfig: config.TargetConfig{Services: []string{"redis"}},
+	}}}
+	if err := c.ApplyConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.warmup(); err == nil || c.Ready() {
+		t.Errorf("Collector shouldn't be ready until projects are discovered")
+	}
+	atomic.StoreInt32(&failing, 0)
+	if err := c.warmup(); err != nil || !c.Ready() {
+		t.Errorf("Collector should be ready once projects are discovered, got [%v]", err)
+	}
+}
+
+func TestWarmupStop(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+	defer func(p string) { redisBasePath = p }(redisBasePath)
+	redisBasePath = ts.URL + "/"
+
+	c := NewSSLCollector([]string{"project-1"}, ts.Client(), false, WithServices([]string{"redis"}))
+	done := make(chan struct{})
+	go func() {
+		c.Warmup()
+		close(done)
+	}()
+	c.Stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Warmup should return once stopped")
+	}
+	if c.Ready() {
+		t.Errorf("Collector shouldn't be ready")
+	}
+}
+
+func TestServeUntilTerminated(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+
+	started := make(chan struct{}, 1)
+	server := &http.Server{Addr: address, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			started <- struct{}{}
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Write([]byte("done"))
+	})}
+	stop := make(chan os.Signal, 1)
+	served := make(chan error, 1)
+	go func() {
+		served <- serveUntilTerminated(server, "", time.Second, stop)
+	}()
+
+	// Spare keep-alive connections would hold the shutdown until its timeout
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+	for i := 0; i < 50; i++ {
+		if res, err := client.Get("http://" + address); err == nil {
+			res.Body.Close()
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	body := make(chan string, 1)
+	go func() {
+		res, err := client.Get("http://" + address + "/slow")
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		b, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		body <- string(b)
+	}()
+	<-started
+	stop <- syscall.SIGTERM
+
+	if b := <-body; b != "done" {
+		t.Errorf("In-flight scrapes should complete, got [%s]", b)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Expected a clean shutdown, got [%s]", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Server didn't shut down")
+	}
+}
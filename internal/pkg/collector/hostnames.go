@@ -0,0 +1,120 @@
+package collector
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/api/compute/v1"
+)
+
+// WithHostnameCoverage enables checking every host rule of the url map behind each httpsProxy
+// against the DNS names of the certificates bind to it
+func WithHostnameCoverage(enabled bool) Option {
+	return func(c *SSLCollector) {
+		if !enabled {
+			return
+		}
+		c.hostnameUncovered = prometheus.NewDesc("gcp_ssl_hostname_uncovered",
+			"Host rule hostname of an httpsProxy url map not covered by any of its certificates",
+			[]string{"proxy", "project", "hostname"}, nil)
+	}
+}
+
+func (c *SSLCollector) collectHostnameCoverage(ctx context.Context, ch chan<- prometheus.Metric, certs []*certificate) {
+	svc, err := c.newComputeService()
+	if err != nil {
+		log.Errorf("Trying to instantiate compute service: [%s]", err)
+		return
+	}
+
+	known := knownComputeCertificates(certs)
+	for _, project := range c.projects {
+		uncovered, err := uncoveredHostnames(ctx, svc, project, known)
+		if err != nil {
+			log.Errorf("%s", err)
+			continue
+		}
+		for proxy, hostnames := range uncovered {
+			for _, hostname := range hostnames {
+				ch <- prometheus.MustNewConstMetric(
+					c.hostnameUncovered, prometheus.GaugeValue, 1, proxy, project, hostname)
+			}
+		}
+	}
+}
+
+// Returns the uncovered hostnames of every httpsProxy by proxy name
+func uncoveredHostnames(ctx context.Context, svc *compute.Service, project string, known map[string]*x509.Certificate) (map[string][]string, error) {
+	httpsProxies, err := svc.TargetHttpsProxies.List(project).Context(ctx).Do()
+	if err != nil {
+		e := fmt.Sprintf("Trying to list httpsProxies in project [%s] with error [%s]", project, err)
+		return nil, errors.New(e)
+	}
+
+	// Same url map could be bind to multiple httpsProxies
+	urlMaps := make(map[string]*compute.UrlMap)
+	uncovered := make(map[string][]string)
+
+	for _, httpsProxy := range httpsProxies.Items {
+		s := strings.Split(httpsProxy.UrlMap, "/")
+		urlMapName := s[len(s)-1]
+
+		urlMap, ok := urlMaps[urlMapName]
+		if !ok {
+			if urlMap, err = svc.UrlMaps.Get(project, urlMapName).Context(ctx).Do(); err != nil {
+				e := fmt.Sprintf("Trying to get url map [%s] in project [%s] with error [%s]", urlMapName, project, err)
+				return nil, errors.New(e)
+			}
+			urlMaps[urlMapName] = urlMap
+		}
+
+		certs, err := lookupComputeCertificates(ctx, svc, project, httpsProxy.SslCertificates, known)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, hostRule := range urlMap.HostRules {
+			for _, host := range hostRule.Hosts {
+				// Catch all host rule matches requests for any hostname
+				if host == "*" || certificatesCover(certs, host) {
+					continue
+				}
+				uncovered[httpsProxy.Name] = append(uncovered[httpsProxy.Name], host)
+			}
+		}
+	}
+	return uncovered, nil
+}
+
+func certificatesCover(certs []*x509.Certificate, hostname string) bool {
+	for _, cert := range certs {
+		if certificateCovers(cert, hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether any DNS SAN of the certificate matches hostname, wildcard SANs match a single left-most
+// label while wildcard hostnames, as those within host rules, are only covered by the same wildcard
+func certificateCovers(cert *x509.Certificate, hostname string) bool {
+	hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+	for _, san := range cert.DNSNames {
+		san = strings.ToLower(strings.TrimSuffix(san, "."))
+		if san == hostname {
+			return true
+		}
+		if !strings.HasPrefix(san, "*.") || strings.HasPrefix(hostname, "*") {
+			continue
+		}
+		if i := strings.Index(hostname, "."); i > 0 && hostname[i:] == san[1:] {
+			return true
+		}
+	}
+	return false
+}
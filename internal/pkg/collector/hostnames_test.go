@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/api/compute/v1"
+)
+
+func TestCertificateCovers(t *testing.T) {
+	cert := &x509.Certificate{DNSNames: []string{"www.example.com", "*.api.example.com"}}
+	for hostname, covered := range map[string]bool{
+		"www.example.com":      true,
+		"WWW.example.com.":     true,
+		"v1.api.example.com":   true,
+		"*.api.example.com":    true,
+		"api.example.com":      false,
+		"a.v1.api.example.com": false,
+		"*.example.com":        false,
+		"shop.example.com":     false,
+	} {
+		if certificateCovers(cert, hostname) != covered {
+			t.Errorf("%s should be covered %v", hostname, covered)
+		}
+	}
+}
+
+func TestUncoveredHostnames(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/project-1/global/targetHttpsProxies":
+			json.NewEncoder(w).Encode(compute.TargetHttpsProxyList{Items: []*compute.TargetHttpsProxy{{
+				Name:            "www-proxy",
+				UrlMap:          "https://www.googleapis.com/compute/v1/projects/project-1/global/urlMaps/www",
+				SslCertificates: []string{"https://www.googleapis.com/compute/v1/projects/project-1/global/sslCertificates/mail"},
+			}}})
+		case "/project-1/global/urlMaps/www":
+			json.NewEncoder(w).Encode(compute.UrlMap{HostRules: []*compute.HostRule{
+				{Hosts: []string{"mail.google.com", "*"}},
+				{Hosts: []string{"calendar.google.com"}},
+			}})
+		case "/project-1/global/sslCertificates/mail":
+			json.NewEncoder(w).Encode(compute.SslCertificate{Name: "mail", Certificate: pemData})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	svc, err := compute.New(ts.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.BasePath = ts.URL + "/"
+
+	uncovered, err := uncoveredHostnames(context.Background(), svc, "project-1", make(map[string]*x509.Certificate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uncovered) != 1 || len(uncovered["www-proxy"]) != 1 || uncovered["www-proxy"][0] != "calendar.google.com" {
+		t.Errorf("Wrong uncovered hostnames %v", uncovered)
+	}
+}
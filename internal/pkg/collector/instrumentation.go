@@ -0,0 +1,128 @@
+package collector
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// APIMetrics counts and times the requests to GCP going through the transports it wraps
+type APIMetrics struct {
+	roots    map[string]string // By API name
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// NewAPIMetrics returns the metrics of GCP API requests, to be registered once. Requests are named after
+// the API whose base URL, as overridden by endpoints, they start with
+func NewAPIMetrics(endpoints map[string]string) *APIMetrics {
+	return &APIMetrics{
+		roots: apiRoots(endpoints),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gcp_ssl_api_requests_total",
+			Help: "Requests to GCP APIs by API, method, project and response code, error when no response was received",
+		}, []string{"api", "method", "project", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "gcp_ssl_api_request_duration_seconds",
+			Help:    "Time until the response headers of GCP API requests were received",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"api", "method", "project"}),
+	}
+}
+
+// Wrap returns a transport recording every request going through next
+func (m *APIMetrics) Wrap(next http.RoundTripper) http.RoundTripper {
+	return &instrumentedTransport{metrics: m, next: next}
+}
+
+// Describe implements prometheus.Collector
+func (m *APIMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requests.Describe(ch)
+	m.duration.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (m *APIMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.requests.Collect(ch)
+	m.duration.Collect(ch)
+}
+
+type instrumentedTransport struct {
+	metrics *APIMetrics
+	next    http.RoundTripper
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	api, method, project := apiMethod(req, t.metrics.roots)
+	start := time.Now()
+	res, err := t.next.RoundTrip(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(res.StatusCode)
+	}
+	t.metrics.requests.WithLabelValues(api, method, project, code).Inc()
+	t.metrics.duration.WithLabelValues(api, method, project).Observe(time.Since(start).Seconds())
+	return res, err
+}
+
+// Collections whose resources aren't followed by an id
+var unnamedCollections = map[string]bool{"global": true, "aggregated": true}
+
+// Names a request after the API whose root its URL starts with, or the first label of its host otherwise,
+// and the collections within its path, such as sslCertificates.list or instances.sslCerts.get. Ids are
+// left out so the number of methods is bounded, except the project one which is returned on its own
+// and empty when the request isn't about a single project
+func apiMethod(req *http.Request, roots map[string]string) (string, string, string) {
+	u := req.URL.Scheme + "://" + req.URL.Host + req.URL.Path
+	api, root := "", req.URL.Scheme+"://"+req.URL.Host+"/"
+	for name, r := range roots {
+		if strings.HasPrefix(u, r) && len(r) > len(root) {
+			api, root = name, r
+		}
+	}
+	// Such as the token endpoints, whose paths have no ids
+	if api == "" {
+		segments := strings.FieldsFunc(req.URL.Path, func(r rune) bool { return r == '/' })
+		return strings.Split(req.URL.Host, ".")[0], strings.Join(append(segments, strings.ToLower(req.Method)), "."), ""
+	}
+
+	path := strings.Trim(strings.TrimPrefix(u, root), "/")
+	verb := ""
+	if i := strings.LastIndex(path, ":"); i >= 0 {
+		path, verb = path[:i], path[i+1:]
+	}
+
+	var collections []string
+	project := ""
+	named := true // Whether the last segment is the id of a resource, so the next one is a collection
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" || unnamedCollections[segment] {
+			continue
+		}
+		if named {
+			collections = append(collections, segment)
+		} else if len(collections) == 1 && collections[0] == "projects" && segment != "-" {
+			project = segment
+		}
+		named = !named
+	}
+	// Parents of nearly every resource tell nothing
+	for len(collections) > 1 && (collections[0] == "projects" || collections[0] == "locations") {
+		collections = collections[1:]
+	}
+
+	if verb == "" {
+		switch {
+		case req.Method != "GET":
+			verb = strings.ToLower(req.Method)
+		case named:
+			verb = "get"
+		default:
+			verb = "list"
+		}
+	}
+	return api, strings.Join(append(collections, verb), "."), project
+}
@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestAPIMethod(t *testing.T) {
+	tests := []struct {
+		method, url        string
+		api, name, project string
+	}{
+		{"GET", "https://www.googleapis.com/compute/v1/projects/p/global/sslCertificates?alt=json", "compute", "sslCertificates.list", "p"},
+		{"GET", "https://www.googleapis.com/compute/v1/projects/p/global/sslCertificates/web", "compute", "sslCertificates.get", "p"},
+		{"GET", "https://www.googleapis.com/compute/v1/projects/p/aggregated/targetHttpsProxies", "compute", "targetHttpsProxies.list", "p"},
+		{"GET", "https://www.googleapis.com/sql/v1beta4/projects/p/instances/db/sslCerts", "sqladmin", "instances.sslCerts.list", "p"},
+		{"GET", "https://www.googleapis.com/dns/v1/projects/p/managedZones/z/rrsets", "dns", "managedZones.rrsets.list", "p"},
+		{"GET", "https://redis.googleapis.com/v1/projects/p/locations/-/instances?pageToken=", "redis", "instances.list", "p"},
+		{"GET", "https://cloudresourcemanager.googleapis.com/v1/projects?filter=labels.env%3Aprod", "cloudresourcemanager", "projects.list", ""},
+		{"POST", "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/sa@p.iam.gserviceaccount.com:generateAccessToken", "iamcredentials", "serviceAccounts.generateAccessToken", ""},
+		{"POST", "https://oauth2.googleapis.com/token", "oauth2", "token.post", ""},
+		{"POST", "https://accounts.google.com/o/oauth2/token", "accounts", "o.oauth2.token.post", ""},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.url, nil)
+		if api, name, project := apiMethod(req, apiRoots(nil)); api != test.api || name != test.name || project != test.project {
+			t.Errorf("Expected [%s] [%s] [%s] for %s, got [%s] [%s] [%s]", test.api, test.name, test.project, test.url, api, name, project)
+		}
+	}
+}
+
+func TestInstrumentedTransport(t *testing.T) {
+	redis := newRedisTestServer(t)
+	defer redis.Close()
+	// Served under the API version as the actual API is
+	ts := httptest.NewServer(http.StripPrefix("/v1", redis.Config.Handler))
+	defer ts.Close()
+	endpoints := map[string]string{"redis": ts.URL + "/v1/"}
+
+	m := NewAPIMetrics(endpoints)
+	client := &http.Client{Transport: m.Wrap(http.DefaultTransport)}
+	c := NewSSLCollector([]string{"project-1"}, client, false, WithServices([]string{"redis"}), WithAPIEndpoints(endpoints))
+	if _, err := c.fetchFromGCP(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ts.Close()
+	if _, err := c.fetchFromGCP(context.Background()); err == nil {
+		t.Fatal("Expected an error once the server is closed")
+	}
+
+	for code, expected := range map[string]float64{"200": 2, "error": 1} {
+		pb := &dto.Metric{}
+		if err := m.requests.WithLabelValues("redis", "instances.list", "project-1", code).Write(pb); err != nil {
+			t.Fatal(err)
+		}
+		if pb.GetCounter().GetValue() != expected {
+			t.Errorf("Expected %v requests with code [%s], got %v", expected, code, pb.GetCounter().GetValue())
+		}
+	}
+	pb := &dto.Metric{}
+	if err := m.duration.WithLabelValues("redis", "instances.list", "project-1").(prometheus.Metric).Write(pb); err != nil {
+		t.Fatal(err)
+	}
+	if pb.GetHistogram().GetSampleCount() != 3 {
+		t.Errorf("Expected 3 observed durations, got %d", pb.GetHistogram().GetSampleCount())
+	}
+}
@@ -0,0 +1,155 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ocsp"
+)
+
+// OCSP responses are small, anything bigger is not an OCSP response
+const maxOCSPResponseBytes = 1 << 20
+
+var ocspStatuses = map[int]string{
+	ocsp.Good:    "good",
+	ocsp.Revoked: "revoked",
+	ocsp.Unknown: "unknown",
+}
+
+// WithOCSP enables checking the revocation status of every certificate against the OCSP responder
+// within its authority information access, the issuer is taken from the uploaded chain. Responders are
+// reached through transport, the one of GCP requests without their metrics, or the default one when nil
+func WithOCSP(enabled bool, timeout time.Duration, transport http.RoundTripper) Option {
+	return func(c *SSLCollector) {
+		if !enabled {
+			return
+		}
+		variableLabels := []string{"name", "project", "service"}
+		c.ocsp = &ocspChecker{
+			client: &http.Client{Transport: transport, Timeout: timeout},
+			cache:  make(map[string]*ocsp.Response),
+			status: prometheus.NewDesc("gcp_ssl_ocsp_status",
+				"OCSP revocation status of an ssl certificate",
+				append(variableLabels, "status"), nil),
+			nextUpdate: prometheus.NewDesc("gcp_ssl_ocsp_next_update_timestamp_seconds",
+				"Time by which the OCSP responder will have newer information about an ssl certificate",
+				variableLabels, nil),
+		}
+	}
+}
+
+type ocspChecker struct {
+	client     *http.Client
+	status     *prometheus.Desc
+	nextUpdate *prometheus.Desc
+
+	mu    sync.Mutex
+	cache map[string]*ocsp.Response // By certificate fingerprint
+}
+
+func (o *ocspChecker) describe(ch chan<- *prometheus.Desc) {
+	ch <- o.status
+	ch <- o.nextUpdate
+}
+
+func (o *ocspChecker) collect(ctx context.Context, ch chan<- prometheus.Metric, cert *certificate) {
+	if cert.x509 == nil || len(cert.x509.OCSPServer) == 0 {
+		return
+	}
+	issuer := findIssuer(cert.x509, cert.chain)
+	if issuer == nil {
+		log.Debugf("Skipping OCSP for certificate [%s] in project [%s] as its issuer wasn't uploaded", cert.name, cert.project)
+		return
+	}
+
+	res, err := o.check(ctx, cert.x509, issuer)
+	if err != nil {
+		log.Errorf("Trying to check OCSP status of certificate [%s] in project [%s] with error [%s]", cert.name, cert.project, err)
+		return
+	}
+
+	labels := []string{cert.name, cert.project, cert.service}
+	for status, name := range ocspStatuses {
+		ch <- prometheus.MustNewConstMetric(o.status, prometheus.GaugeValue, boolToFloat(res.Status == status),
+			append(labels, name)...)
+	}
+	if !res.NextUpdate.IsZero() {
+		ch <- prometheus.MustNewConstMetric(o.nextUpdate, prometheus.GaugeValue, float64(res.NextUpdate.Unix()), labels...)
+	}
+}
+
+// Responses are cached until their next update, those without one are requested every time
+func (o *ocspChecker) check(ctx context.Context, leaf, issuer *x509.Certificate) (*ocsp.Response, error) {
+	key := fingerprint(leaf)
+	o.mu.Lock()
+	res, ok := o.cache[key]
+	o.mu.Unlock()
+	if ok && time.Now().Before(res.NextUpdate) {
+		return res, nil
+	}
+
+	res, err := o.request(ctx, leaf, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	o.mu.Lock()
+	o.cache[key] = res
+	o.mu.Unlock()
+	return res, nil
+}
+
+func (o *ocspChecker) request(ctx context.Context, leaf, issuer *x509.Certificate) (*ocsp.Response, error) {
+	req, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, server := range leaf.OCSPServer {
+		httpReq, err := http.NewRequest("POST", server, bytes.NewReader(req))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		httpReq.Header.Set("Content-Type", "application/ocsp-request")
+		httpRes, err := o.client.Do(httpReq.WithContext(ctx))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(httpRes.Body, maxOCSPResponseBytes))
+		httpRes.Body.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if httpRes.StatusCode != http.StatusOK {
+			e := fmt.Sprintf("OCSP responder [%s] answered with status [%d]", server, httpRes.StatusCode)
+			lastErr = errors.New(e)
+			continue
+		}
+		return ocsp.ParseResponseForCert(body, leaf, issuer)
+	}
+	return nil, lastErr
+}
+
+// Returns the certificate within the uploaded chain which issued leaf
+func findIssuer(leaf *x509.Certificate, chain []*x509.Certificate) *x509.Certificate {
+	for _, c := range chain {
+		if issuedBy(leaf, c) {
+			return c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package collector
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestOCSPCollect(t *testing.T) {
+	issuer, issuerKey := newTestCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+
+	// Responders are reached through the proxy of GCP requests
+	var requests int32
+	nextUpdate := time.Now().Add(time.Hour).Truncate(time.Second)
+	revoked := big.NewInt(0)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "ocsp.example.invalid" {
+			t.Errorf("Wrong proxied host %s", r.Host)
+		}
+		atomic.AddInt32(&requests, 1)
+		body, _ := ioutil.ReadAll(r.Body)
+		req, err := ocsp.ParseRequest(body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		status := ocsp.Good
+		if req.SerialNumber.Cmp(revoked) == 0 {
+			status = ocsp.Revoked
+		}
+		res, err := ocsp.CreateResponse(issuer, issuer, ocsp.Response{
+			Status:       status,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Hour),
+			NextUpdate:   nextUpdate,
+			RevokedAt:    time.Now().Add(-time.Hour),
+		}, issuerKey)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(res)
+	}))
+	defer ts.Close()
+
+	newLeaf := func(cn string) *certificate {
+		leaf, _ := newTestCertificate(t, &x509.Certificate{
+			Subject:    pkix.Name{CommonName: cn},
+			OCSPServer: []string{"http://ocsp.example.invalid"},
+		}, issuer, issuerKey)
+		return &certificate{name: cn, project: "project-1", service: "compute", x509: leaf, chain: []*x509.Certificate{issuer}}
+	}
+	good := newLeaf("good.example.com")
+	bad := newLeaf("revoked.example.com")
+	revoked = bad.x509.SerialNumber
+
+	transport, err := NewTransport(ts.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewSSLCollector(nil, nil, false, WithOCSP(true, time.Second, transport))
+	statuses := make(map[string]string)
+	for i := 0; i < 2; i++ {
+		for _, cert := range []*certificate{good, bad} {
+			ch := make(chan prometheus.Metric, 10)
+			c.ocsp.collect(context.Background(), ch, cert)
+			close(ch)
+
+			for m := range ch {
+				pb := &dto.Metric{}
+				if err := m.Write(pb); err != nil {
+					t.Fatal(err)
+				}
+				if strings.Contains(m.Desc().String(), "gcp_ssl_ocsp_next_update_timestamp_seconds") {
+					if int64(pb.GetGauge().GetValue()) != nextUpdate.Unix() {
+						t.Errorf("Wrong next update %v", pb.GetGauge().GetValue())
+					}
+					continue
+				}
+				for _, l := range pb.GetLabel() {
+					if l.GetName() == "status" && pb.GetGauge().GetValue() == 1 {
+						statuses[cert.name] = l.GetValue()
+					}
+				}
+			}
+		}
+	}
+
+	if statuses["good.example.com"] != "good" || statuses["revoked.example.com"] != "revoked" {
+		t.Errorf("Wrong OCSP statuses %v", statuses)
+	}
+	// Second round is answered from the cache
+	if atomic.LoadInt32(&requests) != 2 {
+		t.Errorf("Wrong number of OCSP requests %d", requests)
+	}
+}
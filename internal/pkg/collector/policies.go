@@ -0,0 +1,145 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/api/compute/v1"
+)
+
+// Proxies without an ssl policy use the GCP default one
+const (
+	defaultSSLPolicy        = "default"
+	defaultSSLPolicyProfile = "COMPATIBLE"
+	defaultSSLPolicyMinTLS  = "TLS_1_0"
+)
+
+// Profiles from the least to the most restrictive, CUSTOM can't be ranked so it ranks lowest
+var sslPolicyProfiles = map[string]int{
+	"CUSTOM":     0,
+	"COMPATIBLE": 1,
+	"MODERN":     2,
+	"RESTRICTED": 3,
+}
+
+var sslPolicyTLSVersions = map[string]int{
+	"TLS_1_0": 0,
+	"TLS_1_1": 1,
+	"TLS_1_2": 2,
+}
+
+// WithSSLPolicies enables exporting the ssl policies of HTTPS and SSL proxies along their compliance
+// with the required profile and minimum TLS version
+func WithSSLPolicies(enabled bool, requiredProfile, requiredMinTLSVersion string) Option {
+	return func(c *SSLCollector) {
+		if !enabled {
+			return
+		}
+		proxyLabels := []string{"proxy", "type", "project", "policy"}
+		c.policies = &sslPolicies{
+			requiredProfile:       requiredProfile,
+			requiredMinTLSVersion: requiredMinTLSVersion,
+			policyInfo: prometheus.NewDesc("gcp_ssl_policy_info",
+				"Profile and minimum TLS version of an ssl policy",
+				[]string{"policy", "project", "profile", "min_tls_version"}, nil),
+			proxyPolicy: prometheus.NewDesc("gcp_ssl_proxy_policy_info",
+				"Ssl policy of an HTTPS or SSL proxy",
+				proxyLabels, nil),
+			proxyCompliant: prometheus.NewDesc("gcp_ssl_proxy_policy_compliant",
+				"Whether the ssl policy of an HTTPS or SSL proxy meets the required profile and minimum TLS version",
+				proxyLabels, nil),
+		}
+	}
+}
+
+type sslPolicies struct {
+	requiredProfile       string
+	requiredMinTLSVersion string
+	policyInfo            *prometheus.Desc
+	proxyPolicy           *prometheus.Desc
+	proxyCompliant        *prometheus.Desc
+}
+
+func (p *sslPolicies) describe(ch chan<- *prometheus.Desc) {
+	ch <- p.policyInfo
+	ch <- p.proxyPolicy
+	ch <- p.proxyCompliant
+}
+
+func (c *SSLCollector) collectSSLPolicies(ctx context.Context, ch chan<- prometheus.Metric) {
+	svc, err := c.newComputeService()
+	if err != nil {
+		log.Errorf("Trying to instantiate compute service: [%s]", err)
+		return
+	}
+
+	for _, project := range c.projects {
+		if err := c.policies.collect(ctx, ch, svc, project); err != nil {
+			log.Errorf("%s", err)
+		}
+	}
+}
+
+func (p *sslPolicies) collect(ctx context.Context, ch chan<- prometheus.Metric, svc *compute.Service, project string) error {
+	policies, err := svc.SslPolicies.List(project).Context(ctx).Do()
+	if err != nil {
+		e := fmt.Sprintf("Trying to list ssl policies in project [%s] with error [%s]", project, err)
+		return errors.New(e)
+	}
+	httpsProxies, err := svc.TargetHttpsProxies.List(project).Context(ctx).Do()
+	if err != nil {
+		e := fmt.Sprintf("Trying to list httpsProxies in project [%s] with error [%s]", project, err)
+		return errors.New(e)
+	}
+	sslProxies, err := svc.TargetSslProxies.List(project).Context(ctx).Do()
+	if err != nil {
+		e := fmt.Sprintf("Trying to list sslProxies in project [%s] with error [%s]", project, err)
+		return errors.New(e)
+	}
+
+	byName := map[string]*compute.SslPolicy{
+		defaultSSLPolicy: {Name: defaultSSLPolicy, Profile: defaultSSLPolicyProfile, MinTlsVersion: defaultSSLPolicyMinTLS},
+	}
+	for _, policy := range policies.Items {
+		byName[policy.Name] = policy
+	}
+	for _, policy := range byName {
+		ch <- prometheus.MustNewConstMetric(p.policyInfo, prometheus.GaugeValue, 1,
+			policy.Name, project, policy.Profile, policy.MinTlsVersion)
+	}
+
+	// HTTPS and SSL proxies may share a name
+	type proxy struct{ name, kind, policy string }
+	proxies := make(map[string]proxy) // By self link
+	for _, p := range httpsProxies.Items {
+		proxies[p.SelfLink] = proxy{p.Name, "https", p.SslPolicy}
+	}
+	for _, p := range sslProxies.Items {
+		proxies[p.SelfLink] = proxy{p.Name, "ssl", p.SslPolicy}
+	}
+
+	for _, proxy := range proxies {
+		name := defaultSSLPolicy
+		if proxy.policy != "" {
+			s := strings.Split(proxy.policy, "/")
+			name = s[len(s)-1]
+		}
+
+		compliant := 0.0
+		if policy, ok := byName[name]; ok && p.complies(policy) {
+			compliant = 1.0
+		}
+		ch <- prometheus.MustNewConstMetric(p.proxyPolicy, prometheus.GaugeValue, 1, proxy.name, proxy.kind, project, name)
+		ch <- prometheus.MustNewConstMetric(p.proxyCompliant, prometheus.GaugeValue, compliant, proxy.name, proxy.kind, project, name)
+	}
+	return nil
+}
+
+func (p *sslPolicies) complies(policy *compute.SslPolicy) bool {
+	return sslPolicyProfiles[policy.Profile] >= sslPolicyProfiles[p.requiredProfile] &&
+		sslPolicyTLSVersions[policy.MinTlsVersion] >= sslPolicyTLSVersions[p.requiredMinTLSVersion]
+}
@@ -0,0 +1,79 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/api/compute/v1"
+)
+
+func TestSSLPoliciesCollect(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/project-1/global/sslPolicies":
+			json.NewEncoder(w).Encode(compute.SslPoliciesList{Items: []*compute.SslPolicy{
+				{Name: "modern", Profile: "MODERN", MinTlsVersion: "TLS_1_2"},
+				{Name: "legacy", Profile: "MODERN", MinTlsVersion: "TLS_1_0"},
+			}})
+		case "/project-1/global/targetHttpsProxies":
+			json.NewEncoder(w).Encode(compute.TargetHttpsProxyList{Items: []*compute.TargetHttpsProxy{
+				{Name: "www", SelfLink: "https://www.googleapis.com/compute/v1/projects/project-1/global/targetHttpsProxies/www", SslPolicy: "https://www.googleapis.com/compute/v1/projects/project-1/global/sslPolicies/modern"},
+				{Name: "api", SelfLink: "https://www.googleapis.com/compute/v1/projects/project-1/global/targetHttpsProxies/api", SslPolicy: "https://www.googleapis.com/compute/v1/projects/project-1/global/sslPolicies/legacy"},
+			}})
+		case "/project-1/global/targetSslProxies":
+			json.NewEncoder(w).Encode(compute.TargetSslProxyList{Items: []*compute.TargetSslProxy{
+				{Name: "smtp", SelfLink: "https://www.googleapis.com/compute/v1/projects/project-1/global/targetSslProxies/smtp"},
+				{Name: "www", SelfLink: "https://www.googleapis.com/compute/v1/projects/project-1/global/targetSslProxies/www"},
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	svc, err := compute.New(ts.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.BasePath = ts.URL + "/"
+
+	c := NewSSLCollector(nil, nil, false, WithSSLPolicies(true, "MODERN", "TLS_1_2"))
+	ch := make(chan prometheus.Metric, 20)
+	if err := c.policies.collect(context.Background(), ch, svc, "project-1"); err != nil {
+		t.Fatal(err)
+	}
+	close(ch)
+
+	compliant := make(map[string]float64) // By type and proxy
+	policies := 0
+	for m := range ch {
+		desc := m.Desc().String()
+		if strings.Contains(desc, "gcp_ssl_policy_info") {
+			policies++
+		}
+		if !strings.Contains(desc, "gcp_ssl_proxy_policy_compliant") {
+			continue
+		}
+		pb := &dto.Metric{}
+		if err := m.Write(pb); err != nil {
+			t.Fatal(err)
+		}
+		labels := make(map[string]string)
+		for _, l := range pb.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		compliant[labels["type"]+"/"+labels["proxy"]] = pb.GetGauge().GetValue()
+	}
+	if policies != 3 {
+		t.Errorf("Wrong number of policies %d", policies)
+	}
+	if compliant["https/www"] != 1 || compliant["https/api"] != 0 || compliant["ssl/smtp"] != 0 || compliant["ssl/www"] != 0 || len(compliant) != 4 {
+		t.Errorf("Wrong proxies compliance %v", compliant)
+	}
+}
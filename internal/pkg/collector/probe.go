@@ -0,0 +1,274 @@
+package collector
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/api/compute/v1"
+)
+
+// Maximum number of TLS handshakes in flight at once
+const probeConcurrency = 10
+
+// WithProbe enables TLS handshakes against the forwarding rules in front of HTTPS and SSL proxies,
+// using every hostname of the certificates configured on each proxy as SNI
+func WithProbe(enabled bool, timeout time.Duration) Option {
+	return func(c *SSLCollector) {
+		if !enabled {
+			return
+		}
+		labels := []string{"proxy", "project", "address", "hostname"}
+		c.prober = &frontendProber{
+			timeout: timeout,
+			success: prometheus.NewDesc("gcp_ssl_probe_success",
+				"Whether the TLS handshake with a load balancer frontend succeeded",
+				labels, nil),
+			servedValidity: prometheus.NewDesc("gcp_ssl_served_validity_seconds",
+				"Time for the ssl certificate served by a load balancer frontend to expire",
+				labels, nil),
+			servedInfo: prometheus.NewDesc("gcp_ssl_served_certificate_info",
+				"SHA-256 fingerprint of the ssl certificate served by a load balancer frontend",
+				append(labels, "fingerprint"), nil),
+			matchesConfigured: prometheus.NewDesc("gcp_ssl_served_matches_configured",
+				"Whether the ssl certificate served by a load balancer frontend is one configured on its proxy",
+				labels, nil),
+		}
+	}
+}
+
+type frontendProber struct {
+	timeout           time.Duration
+	success           *prometheus.Desc
+	servedValidity    *prometheus.Desc
+	servedInfo        *prometheus.Desc
+	matchesConfigured *prometheus.Desc
+}
+
+func (p *frontendProber) describe(ch chan<- *prometheus.Desc) {
+	ch <- p.success
+	ch <- p.servedValidity
+	ch <- p.servedInfo
+	ch <- p.matchesConfigured
+}
+
+// A forwarding rule address in front of an HTTPS or SSL proxy
+type frontend struct {
+	project          string
+	proxy            string
+	address          string
+	certificates     []*x509.Certificate // Configured on the proxy
+	certificateNames []string
+}
+
+type probeResult struct {
+	frontend *frontend
+	hostname string
+	served   *x509.Certificate
+	err      error
+}
+
+// Discover frontends of every project, certificates already fetched from compute are reused as the
+// configured ones and any other is requested. Projects failing discovery are left out
+func (c *SSLCollector) discoverAllFrontends(ctx context.Context, certs []*certificate) []*frontend {
+	svc, err := c.newComputeService()
+	if err != nil {
+		log.Errorf("Trying to instantiate compute service: [%s]", err)
+		return nil
+	}
+
+	known := knownComputeCertificates(certs)
+
+	var frontends []*frontend
+	for _, project := range c.projects {
+		f, err := discoverFrontends(ctx, svc, project, known)
+		if err != nil {
+			log.Errorf("%s", err)
+			continue
+		}
+		frontends = append(frontends, f...)
+	}
+	return frontends
+}
+
+func discoverFrontends(ctx context.Context, svc *compute.Service, project string, known map[string]*x509.Certificate) ([]*frontend, error) {
+	// Proxy self links to their name and configured certificate URIs
+	proxies := make(map[string]*frontend)
+	certURIs := make(map[string][]string)
+
+	httpsProxies, err := svc.TargetHttpsProxies.List(project).Context(ctx).Do()
+	if err != nil {
+		e := fmt.Sprintf("Trying to list httpsProxies in project [%s] with error [%s]", project, err)
+		return nil, errors.New(e)
+	}
+	for _, p := range httpsProxies.Items {
+		proxies[p.SelfLink] = &frontend{project: project, proxy: p.Name}
+		certURIs[p.SelfLink] = p.SslCertificates
+	}
+
+	sslProxies, err := svc.TargetSslProxies.List(project).Context(ctx).Do()
+	if err != nil {
+		e := fmt.Sprintf("Trying to list sslProxies in project [%s] with error [%s]", project, err)
+		return nil, errors.New(e)
+	}
+	for _, p := range sslProxies.Items {
+		proxies[p.SelfLink] = &frontend{project: project, proxy: p.Name}
+		certURIs[p.SelfLink] = p.SslCertificates
+	}
+
+	rules, err := svc.GlobalForwardingRules.List(project).Context(ctx).Do()
+	if err != nil {
+		e := fmt.Sprintf("Trying to list global forwarding rules in project [%s] with error [%s]", project, err)
+		return nil, errors.New(e)
+	}
+
+	var frontends []*frontend
+	for _, rule := range rules.Items {
+		proxy, ok := proxies[rule.Target]
+		if !ok {
+			continue
+		}
+
+		configured, err := lookupComputeCertificates(ctx, svc, project, certURIs[rule.Target], known)
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, uri := range certURIs[rule.Target] {
+			s := strings.Split(uri, "/")
+			names = append(names, s[len(s)-1])
+		}
+
+		// Port ranges of target proxies forwarding rules hold a single port
+		port := strings.Split(rule.PortRange, "-")[0]
+		frontends = append(frontends, &frontend{
+			project:          project,
+			proxy:            proxy.proxy,
+			address:          net.JoinHostPort(rule.IPAddress, port),
+			certificates:     configured,
+			certificateNames: names,
+		})
+	}
+	return frontends, nil
+}
+
+// Every frontend is probed once per hostname within its configured certificates, wildcards
+// can't be used as SNI so they are skipped, frontends without any hostname are probed without SNI
+func probeHostnames(f *frontend) []string {
+	seen := make(map[string]bool)
+	var hostnames []string
+	for _, cert := range f.certificates {
+		for _, name := range cert.DNSNames {
+			if strings.HasPrefix(name, "*") || seen[name] {
+				continue
+			}
+			seen[name] = true
+			hostnames = append(hostnames, name)
+		}
+	}
+	if len(hostnames) == 0 {
+		return []string{""}
+	}
+	return hostnames
+}
+
+// Probes left once ctx is done aren't started nor reported
+func (p *frontendProber) collect(ctx context.Context, ch chan<- prometheus.Metric, frontends []*frontend) {
+	results := make(chan *probeResult)
+	sem := make(chan struct{}, probeConcurrency)
+	var wg sync.WaitGroup
+
+	for _, f := range frontends {
+		for _, hostname := range probeHostnames(f) {
+			wg.Add(1)
+			go func(f *frontend, hostname string) {
+				defer wg.Done()
+				sem <- struct{}{}
+				if ctx.Err() != nil {
+					<-sem
+					return
+				}
+				served, err := probe(ctx, f.address, hostname, p.timeout)
+				<-sem
+				results <- &probeResult{frontend: f, hostname: hostname, served: served, err: err}
+			}(f, hostname)
+		}
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for r := range results {
+		labels := []string{r.frontend.proxy, r.frontend.project, r.frontend.address, r.hostname}
+		if r.err != nil {
+			log.Errorf("Trying to probe [%s] with hostname [%s] with error [%s]", r.frontend.address, r.hostname, r.err)
+			ch <- prometheus.MustNewConstMetric(p.success, prometheus.GaugeValue, 0, labels...)
+			continue
+		}
+
+		matches := 0.0
+		served := fingerprint(r.served)
+		for _, cert := range r.frontend.certificates {
+			if fingerprint(cert) == served {
+				matches = 1.0
+				break
+			}
+		}
+
+		ch <- prometheus.MustNewConstMetric(p.success, prometheus.GaugeValue, 1, labels...)
+		ch <- prometheus.MustNewConstMetric(p.servedValidity, prometheus.GaugeValue,
+			float64(r.served.NotAfter.Unix()-time.Now().Unix()), labels...)
+		ch <- prometheus.MustNewConstMetric(p.servedInfo, prometheus.GaugeValue, 1, append(labels, served)...)
+		ch <- prometheus.MustNewConstMetric(p.matchesConfigured, prometheus.GaugeValue, matches, labels...)
+	}
+}
+
+// Returns the leaf certificate served by address for the given SNI hostname, it is not verified
+// as serving an invalid certificate is what we want to find out
+func probe(ctx context.Context, address, hostname string, timeout time.Duration) (*x509.Certificate, error) {
+	conn, err := dialProbe(ctx, address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName:         hostname,
+		InsecureSkipVerify: true,
+	})
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, err
+	}
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, errors.New("No certificate served")
+	}
+	return certs[0], nil
+}
+
+// Connects to address within timeout, the connection deadline being the earliest of timeout and the one of ctx
+// so the handshakes that follow don't outlive the scrape
+func dialProbe(ctx context.Context, address string, timeout time.Duration) (net.Conn, error) {
+	conn, err := (&net.Dialer{Timeout: timeout}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
@@ -0,0 +1,111 @@
+package collector
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestProbe(t *testing.T) {
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	served, err := probe(context.Background(), ts.Listener.Addr().String(), "example.com", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fingerprint(served) != fingerprint(ts.Certificate()) {
+		t.Errorf("Served certificate %s is not the server one", served.Subject)
+	}
+
+	ts.Close()
+	if _, err := probe(context.Background(), ts.Listener.Addr().String(), "example.com", time.Second); err == nil {
+		t.Error("there should have been an error")
+	}
+}
+
+func TestProbeScrapeDeadline(t *testing.T) {
+	// Accepts connections without ever answering the handshake
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := probe(ctx, l.Addr().String(), "example.com", time.Minute); err == nil {
+		t.Error("there should have been an error")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("The handshake should end with the scrape, took %s", time.Since(start))
+	}
+}
+
+func TestProbeCollect(t *testing.T) {
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	other, err := parseCertificate(pemData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewSSLCollector(nil, nil, false, WithProbe(true, time.Second))
+	frontends := []*frontend{
+		{project: "project-1", proxy: "current", address: ts.Listener.Addr().String(),
+			certificates: []*x509.Certificate{ts.Certificate()}},
+		{project: "project-1", proxy: "outdated", address: ts.Listener.Addr().String(),
+			certificates: []*x509.Certificate{other}},
+	}
+
+	ch := make(chan prometheus.Metric, 20)
+	c.prober.collect(context.Background(), ch, frontends)
+	close(ch)
+
+	matches := make(map[string]float64)
+	for m := range ch {
+		if !strings.Contains(m.Desc().String(), "gcp_ssl_served_matches_configured") {
+			continue
+		}
+		pb := &dto.Metric{}
+		if err := m.Write(pb); err != nil {
+			t.Fatal(err)
+		}
+		for _, l := range pb.GetLabel() {
+			if l.GetName() == "proxy" {
+				matches[l.GetValue()+"/"+hostnameLabel(pb)] = pb.GetGauge().GetValue()
+			}
+		}
+	}
+	if matches["current/example.com"] != 1 || matches["outdated/mail.google.com"] != 0 || len(matches) != 2 {
+		t.Errorf("Wrong served certificate matches %v", matches)
+	}
+}
+
+func hostnameLabel(pb *dto.Metric) string {
+	for _, l := range pb.GetLabel() {
+		if l.GetName() == "hostname" {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,124 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// The vendored google-api-go-client redis/v1 predates in-transit encryption,
+// therefore the Memorystore API is requested directly
+var redisBasePath = "https://redis.googleapis.com/v1/"
+
+type redisInstanceList struct {
+	Instances     []*redisInstance `json:"instances"`
+	NextPageToken string           `json:"nextPageToken"`
+	Unreachable   []string         `json:"unreachable"`
+}
+
+type redisInstance struct {
+	Name                  string                 `json:"name"`
+	TransitEncryptionMode string                 `json:"transitEncryptionMode"`
+	ServerCaCerts         []*redisTLSCertificate `json:"serverCaCerts"`
+}
+
+type redisTLSCertificate struct {
+	SerialNumber string `json:"serialNumber"`
+	Cert         string `json:"cert"`
+}
+
+// Fetch server CA certificates from every redis instance with in-transit encryption across all regions
+func (c *SSLCollector) fetchFromRedis(ctx context.Context) ([]*certificate, error) {
+	var projectsCertificates []*certificate
+
+	for _, project := range c.projects {
+		instances, err := c.listRedisInstances(ctx, project)
+		// TODO: Return data from successfull projects in partial failures scenarios
+		if err != nil {
+			e := fmt.Sprintf("Trying to list redis instances in project [%s] with error [%s]", project, err)
+			return projectsCertificates, errors.New(e)
+		}
+
+		certs, err := toInternalCertificates(getCertificateFromRedisAPICertificate(instances), project)
+		if err != nil {
+			return projectsCertificates, err
+		}
+		projectsCertificates = append(projectsCertificates, certs...)
+	}
+	return projectsCertificates, nil
+}
+
+func (c *SSLCollector) listRedisInstances(ctx context.Context, project string) ([]*redisInstance, error) {
+	var instances []*redisInstance
+	pageToken := ""
+	for {
+		u := fmt.Sprintf("%sprojects/%s/locations/-/instances?pageToken=%s",
+			c.apiBasePath("redis"), url.PathEscape(project), url.QueryEscape(pageToken))
+
+		var list redisInstanceList
+		if err := getJSON(ctx, c.httpClient, u, &list); err != nil {
+			return nil, err
+		}
+		for _, location := range list.Unreachable {
+			log.Warnf("Redis location [%s] in project [%s] is unreachable", location, project)
+		}
+		instances = append(instances, list.Instances...)
+
+		if list.NextPageToken == "" {
+			return instances, nil
+		}
+		pageToken = list.NextPageToken
+	}
+}
+
+func getCertificateFromRedisAPICertificate(instances []*redisInstance) []*gcpCertificate {
+	var gcpCerts []*gcpCertificate
+	for _, i := range instances {
+		name := redisInstanceName(i.Name)
+		for _, c := range i.ServerCaCerts {
+			gcpCerts = append(gcpCerts, &gcpCertificate{
+				name:     fmt.Sprintf("%s-%s", name, c.SerialNumber),
+				raw:      c.Cert,
+				service:  "redis",
+				instance: name,
+			})
+		}
+	}
+	return gcpCerts
+}
+
+// Instance names come in the form projects/{project}/locations/{location}/instances/{instance},
+// instance ids are unique per location only
+func redisInstanceName(name string) string {
+	s := strings.Split(name, "/")
+	if len(s) < 3 {
+		return name
+	}
+	return fmt.Sprintf("%s-%s", s[len(s)-3], s[len(s)-1])
+}
+
+// A redis instance lists more than one server CA while a rotation is taking place
+func (c *SSLCollector) collectRedisCARotation(ch chan<- prometheus.Metric, certs []*certificate) {
+	type instanceKey struct{ name, project string }
+	cas := make(map[instanceKey]int)
+	for _, cert := range certs {
+		if cert.service != "redis" {
+			continue
+		}
+		cas[instanceKey{cert.instance, cert.project}]++
+	}
+
+	for k, n := range cas {
+		rotating := 0.0
+		if n > 1 {
+			rotating = 1.0
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.redisCARotation, prometheus.GaugeValue, rotating, k.name, k.project)
+	}
+}
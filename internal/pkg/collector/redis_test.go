@@ -0,0 +1,98 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func newRedisTestServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/projects/project-1/locations/-/instances" {
+			t.Errorf("Unexpected request path %s", r.URL.Path)
+		}
+		var list redisInstanceList
+		switch r.URL.Query().Get("pageToken") {
+		case "":
+			list = redisInstanceList{
+				Instances: []*redisInstance{{
+					Name:                  "projects/project-1/locations/us-central1/instances/cache",
+					TransitEncryptionMode: "SERVER_AUTHENTICATION",
+					ServerCaCerts: []*redisTLSCertificate{
+						{SerialNumber: "1", Cert: pemData},
+						{SerialNumber: "2", Cert: pemData},
+					},
+				}},
+				NextPageToken: "next",
+			}
+		case "next":
+			list = redisInstanceList{
+				Instances: []*redisInstance{
+					{
+						Name:                  "projects/project-1/locations/europe-west1/instances/sessions",
+						TransitEncryptionMode: "SERVER_AUTHENTICATION",
+						ServerCaCerts:         []*redisTLSCertificate{{SerialNumber: "3", Cert: pemData}},
+					},
+					{
+						Name:                  "projects/project-1/locations/europe-west1/instances/plaintext",
+						TransitEncryptionMode: "DISABLED",
+					},
+				},
+			}
+		}
+		json.NewEncoder(w).Encode(list)
+	}))
+}
+
+func TestFetchFromRedis(t *testing.T) {
+	ts := newRedisTestServer(t)
+	defer ts.Close()
+	defer func(p string) { redisBasePath = p }(redisBasePath)
+	redisBasePath = ts.URL + "/"
+
+	c := NewSSLCollector([]string{"project-1"}, ts.Client(), false, WithServices([]string{"redis"}))
+	certs, err := c.fetchFromGCP(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 3 {
+		t.Fatalf("Wrong number of certs, %d should be %d", len(certs), 3)
+	}
+	if certs[0].name != "us-central1-cache-1" || certs[0].service != "redis" || certs[0].project != "project-1" {
+		t.Errorf("The following certificate struc is wrong %#v", certs[0])
+	}
+}
+
+func TestCollectRedisCARotation(t *testing.T) {
+	ts := newRedisTestServer(t)
+	defer ts.Close()
+	defer func(p string) { redisBasePath = p }(redisBasePath)
+	redisBasePath = ts.URL + "/"
+
+	c := NewSSLCollector([]string{"project-1"}, ts.Client(), false, WithServices([]string{"redis"}))
+	certs, err := c.fetchFromRedis(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan prometheus.Metric, 10)
+	c.collectRedisCARotation(ch, certs)
+	close(ch)
+
+	rotating := make(map[string]float64)
+	for m := range ch {
+		pb := &dto.Metric{}
+		if err := m.Write(pb); err != nil {
+			t.Fatal(err)
+		}
+		rotating[pb.GetLabel()[0].GetValue()] = pb.GetGauge().GetValue()
+	}
+	if rotating["us-central1-cache"] != 1 || rotating["europe-west1-sessions"] != 0 {
+		t.Errorf("Wrong rotation status %v", rotating)
+	}
+}
@@ -0,0 +1,109 @@
+package collector
+
+import (
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/snebel29/prometheus-gcp-ssl-exporter/internal/pkg/config"
+)
+
+// ConfigReloader applies the config file to a collector, keeping the last applied config when it's invalid
+type ConfigReloader struct {
+	path      string
+	collector *SSLCollector
+
+	mu                         sync.Mutex
+	lastReloadSuccessful       prometheus.Gauge
+	lastReloadSuccessTimestamp prometheus.Gauge
+	hup                        chan os.Signal
+}
+
+// NewConfigReloader returns a reloader of the config file in path
+func NewConfigReloader(path string, collector *SSLCollector) *ConfigReloader {
+	return &ConfigReloader{
+		path:      path,
+		collector: collector,
+		lastReloadSuccessful: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "gcp_ssl_config_last_reload_successful",
+			Help: "Whether the last config reload attempt was successful",
+		}),
+		lastReloadSuccessTimestamp: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "gcp_ssl_config_last_reload_success_timestamp_seconds",
+			Help: "Timestamp of the last successful config reload",
+		}),
+	}
+}
+
+// Reload loads, validates and applies the config file
+func (r *ConfigReloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := config.Load(r.path)
+	if err == nil {
+		err = r.collector.ApplyConfig(cfg)
+	}
+	if err != nil {
+		r.lastReloadSuccessful.Set(0)
+		return err
+	}
+	r.lastReloadSuccessful.Set(1)
+	r.lastReloadSuccessTimestamp.Set(float64(time.Now().Unix()))
+	log.Infof("Loaded config file [%s]", r.path)
+	return nil
+}
+
+// Handler reloads the config file on POST requests
+func (r *ConfigReloader) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Only POST requests reload the config", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := r.Reload(); err != nil {
+			log.Errorf("%s", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// WatchSignals reloads the config file on every SIGHUP until stopped
+func (r *ConfigReloader) WatchSignals() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	r.hup = hup
+	go func() {
+		for range hup {
+			if err := r.Reload(); err != nil {
+				log.Errorf("%s", err)
+			}
+		}
+	}()
+}
+
+// Stop stops reloading the config file on SIGHUP
+func (r *ConfigReloader) Stop() {
+	if r.hup != nil {
+		signal.Stop(r.hup)
+		close(r.hup)
+	}
+}
+
+// Describe implements prometheus.Collector
+func (r *ConfigReloader) Describe(ch chan<- *prometheus.Desc) {
+	r.lastReloadSuccessful.Describe(ch)
+	r.lastReloadSuccessTimestamp.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (r *ConfigReloader) Collect(ch chan<- prometheus.Metric) {
+	r.lastReloadSuccessful.Collect(ch)
+	r.lastReloadSuccessTimestamp.Collect(ch)
+}
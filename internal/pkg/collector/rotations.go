@@ -0,0 +1,175 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Keeps the fingerprint behind every certificate name across refreshes to tell when it was replaced,
+// the first refresh only records what exists so nothing is counted as created
+type rotationTracker struct {
+	mu           sync.Mutex
+	initialized  bool
+	certificates map[certificateKey]*trackedCertificate
+	created      map[string]float64 // By project
+	deleted      map[string]float64 // By project
+
+	rotations    *prometheus.Desc
+	lastRotation *prometheus.Desc
+	createdTotal *prometheus.Desc
+	deletedTotal *prometheus.Desc
+}
+
+type certificateKey struct {
+	name    string
+	project string
+	service string
+}
+
+type trackedCertificate struct {
+	fingerprint  string
+	rotations    float64
+	lastRotation time.Time
+}
+
+func newRotationTracker(variableLabels []string) *rotationTracker {
+	return &rotationTracker{
+		certificates: make(map[certificateKey]*trackedCertificate),
+		created:      make(map[string]float64),
+		deleted:      make(map[string]float64),
+		rotations: prometheus.NewDesc("gcp_ssl_certificate_rotations_total",
+			"Number of times the ssl certificate behind a name was replaced",
+			variableLabels, nil),
+		lastRotation: prometheus.NewDesc("gcp_ssl_last_rotation_timestamp_seconds",
+			"Time the ssl certificate behind a name was last seen replaced",
+			variableLabels, nil),
+		createdTotal: prometheus.NewDesc("gcp_ssl_certificates_created_total",
+			"Number of ssl certificates seen appearing in a project",
+			[]string{"project"}, nil),
+		deletedTotal: prometheus.NewDesc("gcp_ssl_certificates_deleted_total",
+			"Number of ssl certificates seen disappearing from a project",
+			[]string{"project"}, nil),
+	}
+}
+
+func (r *rotationTracker) describe(ch chan<- *prometheus.Desc) {
+	ch <- r.rotations
+	ch <- r.lastRotation
+	ch <- r.createdTotal
+	ch <- r.deletedTotal
+}
+
+func (r *rotationTracker) observe(certs []*certificate) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.update(certs, time.Now())
+}
+
+func (r *rotationTracker) collect(ch chan<- prometheus.Metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, tracked := range r.certificates {
+		labels := []string{key.name, key.project, key.service}
+		ch <- prometheus.MustNewConstMetric(r.rotations, prometheus.CounterValue, tracked.rotations, labels...)
+		if !tracked.lastRotation.IsZero() {
+			ch <- prometheus.MustNewConstMetric(r.lastRotation, prometheus.GaugeValue,
+				float64(tracked.lastRotation.Unix()), labels...)
+		}
+	}
+	for project, n := range r.created {
+		ch <- prometheus.MustNewConstMetric(r.createdTotal, prometheus.CounterValue, n, project)
+	}
+	for project, n := range r.deleted {
+		ch <- prometheus.MustNewConstMetric(r.deletedTotal, prometheus.CounterValue, n, project)
+	}
+}
+
+func (r *rotationTracker) update(certs []*certificate, now time.Time) {
+	seen := make(map[certificateKey]bool)
+	for _, cert := range certs {
+		if cert.x509 == nil {
+			continue
+		}
+		key := certificateKey{cert.name, cert.project, cert.service}
+		f := fingerprint(cert.x509)
+		seen[key] = true
+		// Projects are exported with zero counts from the moment they have certificates
+		if _, ok := r.created[cert.project]; !ok {
+			r.created[cert.project] = 0
+			r.deleted[cert.project] = 0
+		}
+
+		tracked, ok := r.certificates[key]
+		switch {
+		case !ok:
+			if r.initialized {
+				r.created[cert.project]++
+			}
+			r.certificates[key] = &trackedCertificate{fingerprint: f}
+		case tracked.fingerprint != f:
+			tracked.fingerprint = f
+			tracked.rotations++
+			tracked.lastRotation = now
+		}
+	}
+
+	for key := range r.certificates {
+		if !seen[key] {
+			r.deleted[key.project]++
+			delete(r.certificates, key)
+		}
+	}
+	r.initialized = true
+}
+
+func (r *rotationTracker) persist() persistedState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state := persistedState{
+		Rotations: []persistedRotation{},
+		Created:   make(map[string]float64),
+		Deleted:   make(map[string]float64),
+	}
+	for key, tracked := range r.certificates {
+		state.Rotations = append(state.Rotations, persistedRotation{
+			Name:         key.name,
+			Project:      key.project,
+			Service:      key.service,
+			Fingerprint:  tracked.fingerprint,
+			Rotations:    tracked.rotations,
+			LastRotation: tracked.lastRotation,
+		})
+	}
+	for project, n := range r.created {
+		state.Created[project] = n
+	}
+	for project, n := range r.deleted {
+		state.Deleted[project] = n
+	}
+	return state
+}
+
+// Restored history counts as a first refresh, so certificates created meanwhile are counted
+func (r *rotationTracker) restore(state persistedState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, p := range state.Rotations {
+		r.certificates[certificateKey{p.Name, p.Project, p.Service}] = &trackedCertificate{
+			fingerprint:  p.Fingerprint,
+			rotations:    p.Rotations,
+			lastRotation: p.LastRotation,
+		}
+	}
+	for project, n := range state.Created {
+		r.created[project] = n
+	}
+	for project, n := range state.Deleted {
+		r.deleted[project] = n
+	}
+	r.initialized = true
+}
@@ -0,0 +1,41 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRotationTrackerUpdate(t *testing.T) {
+	_, _, leaves := newTestChain(t, "a.example.com", "b.example.com", "renewed-a.example.com")
+	cert := func(name, project string, i int) *certificate {
+		return &certificate{name: name, project: project, service: "compute", x509: leaves[i]}
+	}
+
+	r := newRotationTracker([]string{"name", "project", "service"})
+	first := time.Unix(1000, 0)
+	r.update([]*certificate{cert("a", "project-1", 0), cert("b", "project-1", 1)}, first)
+	if r.created["project-1"] != 0 || r.deleted["project-1"] != 0 || len(r.certificates) != 2 {
+		t.Errorf("First refresh should only record certificates %v %v", r.created, r.deleted)
+	}
+
+	// a is renewed, b deleted and c created in another project
+	second := time.Unix(2000, 0)
+	r.update([]*certificate{cert("a", "project-1", 2), cert("c", "project-2", 1)}, second)
+
+	a := r.certificates[certificateKey{"a", "project-1", "compute"}]
+	if a == nil || a.rotations != 1 || !a.lastRotation.Equal(second) || a.fingerprint != fingerprint(leaves[2]) {
+		t.Errorf("Wrong rotation of certificate a %+v", a)
+	}
+	if _, ok := r.certificates[certificateKey{"b", "project-1", "compute"}]; ok {
+		t.Error("Certificate b should no longer be tracked")
+	}
+	if r.deleted["project-1"] != 1 || r.created["project-2"] != 1 || r.created["project-1"] != 0 {
+		t.Errorf("Wrong created %v and deleted %v counts", r.created, r.deleted)
+	}
+
+	// Nothing changes when the same certificates are seen again
+	r.update([]*certificate{cert("a", "project-1", 2), cert("c", "project-2", 1)}, time.Unix(3000, 0))
+	if a.rotations != 1 || !a.lastRotation.Equal(second) || r.created["project-2"] != 1 {
+		t.Errorf("Unexpected changes %+v %v", a, r.created)
+	}
+}
@@ -0,0 +1,162 @@
+package collector
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Workload identity pools have no client within the vendored google-api-go-client,
+// therefore the IAM API is requested directly
+var iamBasePath = "https://iam.googleapis.com/v1/"
+
+type workloadIdentityPoolList struct {
+	WorkloadIdentityPools []*workloadIdentityPool `json:"workloadIdentityPools"`
+	NextPageToken         string                  `json:"nextPageToken"`
+}
+
+type workloadIdentityPool struct {
+	Name string `json:"name"`
+}
+
+type workloadIdentityPoolProviderList struct {
+	WorkloadIdentityPoolProviders []*workloadIdentityPoolProvider `json:"workloadIdentityPoolProviders"`
+	NextPageToken                 string                          `json:"nextPageToken"`
+}
+
+type workloadIdentityPoolProvider struct {
+	Name string `json:"name"`
+	Saml *struct {
+		IdpMetadataXML string `json:"idpMetadataXml"`
+	} `json:"saml"`
+}
+
+// Only the parts of the SAML 2.0 metadata holding the IdP signing certificates
+type samlEntityDescriptor struct {
+	IDPSSODescriptors []struct {
+		KeyDescriptors []struct {
+			Use          string   `xml:"use,attr"`
+			Certificates []string `xml:"KeyInfo>X509Data>X509Certificate"`
+		} `xml:"KeyDescriptor"`
+	} `xml:"IDPSSODescriptor"`
+}
+
+// Fetch IdP signing certificates from the metadata of every workload identity pool SAML provider
+func (c *SSLCollector) fetchFromSAML(ctx context.Context) ([]*certificate, error) {
+	var projectsCertificates []*certificate
+
+	for _, project := range c.projects {
+		providers, err := c.listSAMLProviders(ctx, project)
+		// TODO: Return data from successfull projects in partial failures scenarios
+		if err != nil {
+			e := fmt.Sprintf("Trying to list workload identity pool providers in project [%s] with error [%s]", project, err)
+			return projectsCertificates, errors.New(e)
+		}
+
+		for _, provider := range providers {
+			gcpCerts, err := getCertificateFromSAMLProvider(provider)
+			if err != nil {
+				e := fmt.Sprintf("Trying to parse metadata of provider [%s] in project [%s] with error [%s]", provider.Name, project, err)
+				return projectsCertificates, errors.New(e)
+			}
+
+			certs, err := toInternalCertificates(gcpCerts, project)
+			if err != nil {
+				return projectsCertificates, err
+			}
+			projectsCertificates = append(projectsCertificates, certs...)
+		}
+	}
+	return projectsCertificates, nil
+}
+
+func (c *SSLCollector) listSAMLProviders(ctx context.Context, project string) ([]*workloadIdentityPoolProvider, error) {
+	var pools []*workloadIdentityPool
+	pageToken := ""
+	for {
+		u := fmt.Sprintf("%sprojects/%s/locations/global/workloadIdentityPools?pageToken=%s",
+			c.apiBasePath("iam"), url.PathEscape(project), url.QueryEscape(pageToken))
+
+		var list workloadIdentityPoolList
+		if err := getJSON(ctx, c.httpClient, u, &list); err != nil {
+			return nil, err
+		}
+		pools = append(pools, list.WorkloadIdentityPools...)
+
+		if list.NextPageToken == "" {
+			break
+		}
+		pageToken = list.NextPageToken
+	}
+
+	var providers []*workloadIdentityPoolProvider
+	for _, pool := range pools {
+		pageToken = ""
+		for {
+			u := fmt.Sprintf("%s%s/providers?pageToken=%s",
+				c.apiBasePath("iam"), pool.Name, url.QueryEscape(pageToken))
+
+			var list workloadIdentityPoolProviderList
+			if err := getJSON(ctx, c.httpClient, u, &list); err != nil {
+				return nil, err
+			}
+			for _, provider := range list.WorkloadIdentityPoolProviders {
+				if provider.Saml != nil {
+					providers = append(providers, provider)
+				}
+			}
+
+			if list.NextPageToken == "" {
+				break
+			}
+			pageToken = list.NextPageToken
+		}
+	}
+	return providers, nil
+}
+
+// Metadata certificates are base64 DER, they are turned into PEM so they go through parseCertificate as any other
+func getCertificateFromSAMLProvider(provider *workloadIdentityPoolProvider) ([]*gcpCertificate, error) {
+	var metadata samlEntityDescriptor
+	if err := xml.Unmarshal([]byte(provider.Saml.IdpMetadataXML), &metadata); err != nil {
+		return nil, err
+	}
+
+	name := samlProviderName(provider.Name)
+	var gcpCerts []*gcpCertificate
+	for _, idp := range metadata.IDPSSODescriptors {
+		for _, key := range idp.KeyDescriptors {
+			// Keys without use apply to both signing and encryption
+			if key.Use != "" && key.Use != "signing" {
+				continue
+			}
+			for _, b64 := range key.Certificates {
+				der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(b64), ""))
+				if err != nil {
+					return nil, err
+				}
+				gcpCerts = append(gcpCerts, &gcpCertificate{
+					name:     fmt.Sprintf("%s-%d", name, len(gcpCerts)),
+					raw:      string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+					service:  "saml",
+					instance: name,
+				})
+			}
+		}
+	}
+	return gcpCerts, nil
+}
+
+// Provider names come in the form projects/{project}/locations/global/workloadIdentityPools/{pool}/providers/{provider}
+func samlProviderName(name string) string {
+	s := strings.Split(name, "/")
+	if len(s) < 4 {
+		return name
+	}
+	return fmt.Sprintf("%s-%s", s[len(s)-3], s[len(s)-1])
+}
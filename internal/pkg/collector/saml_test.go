@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const samlMetadataTemplate = `<?xml version="1.0"?>
+<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" entityID="https://idp.example.com">
+  <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:KeyDescriptor use="signing">
+      <ds:KeyInfo><ds:X509Data><ds:X509Certificate>
+%[1]s
+      </ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+    </md:KeyDescriptor>
+    <md:KeyDescriptor use="encryption">
+      <ds:KeyInfo><ds:X509Data><ds:X509Certificate>%[1]s</ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+    </md:KeyDescriptor>
+    <md:KeyDescriptor>
+      <ds:KeyInfo><ds:X509Data><ds:X509Certificate>%[1]s</ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+    </md:KeyDescriptor>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`
+
+func TestFetchFromSAML(t *testing.T) {
+	block, _ := pem.Decode([]byte(pemData))
+	metadata := fmt.Sprintf(samlMetadataTemplate, base64.StdEncoding.EncodeToString(block.Bytes))
+	pool := "projects/project-1/locations/global/workloadIdentityPools/corp"
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/projects/project-1/locations/global/workloadIdentityPools":
+			json.NewEncoder(w).Encode(workloadIdentityPoolList{
+				WorkloadIdentityPools: []*workloadIdentityPool{{Name: pool}},
+			})
+		case "/" + pool + "/providers":
+			var providers workloadIdentityPoolProviderList
+			json.Unmarshal([]byte(fmt.Sprintf(
+				`{"workloadIdentityPoolProviders": [{"name": "%[1]s/providers/oidc", "oidc": {}}, {"name": "%[1]s/providers/okta", "saml": {"idpMetadataXml": %[2]q}}]}`,
+				pool, metadata)), &providers)
+			json.NewEncoder(w).Encode(providers)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	defer func(p string) { iamBasePath = p }(iamBasePath)
+	iamBasePath = ts.URL + "/"
+
+	c := NewSSLCollector([]string{"project-1"}, ts.Client(), false, WithServices([]string{"saml"}))
+	certs, err := c.fetchFromGCP(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 2 {
+		t.Fatalf("Wrong number of certs, %d should be %d", len(certs), 2)
+	}
+	if certs[0].name != "corp-okta-0" || certs[0].service != "saml" || certs[0].project != "project-1" {
+		t.Errorf("The following certificate struc is wrong %#v", certs[0])
+	}
+}
@@ -0,0 +1,55 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prometheus tells how long it waits for a scrape within this header
+const scrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+
+// MetricsHandler serves the collector along the default registry, GCP calls are cancelled offset
+// before Prometheus gives up on the scrape so whatever was fetched until then is still served
+func MetricsHandler(c *SSLCollector, offset time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := scrapeContext(r, offset)
+		defer cancel()
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(&scrapeCollector{collector: c, ctx: ctx})
+		gatherers := prometheus.Gatherers{prometheus.DefaultGatherer, registry}
+		promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	})
+}
+
+// Binds a collector to the context of a single scrape
+type scrapeCollector struct {
+	collector *SSLCollector
+	ctx       context.Context
+}
+
+func (s *scrapeCollector) Describe(ch chan<- *prometheus.Desc) {
+	s.collector.Describe(ch)
+}
+
+func (s *scrapeCollector) Collect(ch chan<- prometheus.Metric) {
+	s.collector.collect(s.ctx, ch)
+}
+
+// Scrapes without the header are only bound to the request, offset only applies to longer timeouts
+func scrapeContext(r *http.Request, offset time.Duration) (context.Context, context.CancelFunc) {
+	seconds, err := strconv.ParseFloat(r.Header.Get(scrapeTimeoutHeader), 64)
+	if err != nil || seconds <= 0 {
+		return context.WithCancel(r.Context())
+	}
+	timeout := time.Duration(seconds * float64(time.Second))
+	if timeout > offset {
+		timeout -= offset
+	}
+	return context.WithTimeout(r.Context(), timeout)
+}
@@ -0,0 +1,90 @@
+package collector
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScrapeContext(t *testing.T) {
+	tests := []struct {
+		header   string
+		offset   time.Duration
+		deadline time.Duration // Zero when there is none
+	}{
+		{"", time.Second, 0},
+		{"invalid", time.Second, 0},
+		{"10", time.Second, 9 * time.Second},
+		{"0.5", time.Second, 500 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		r.Header.Set(scrapeTimeoutHeader, test.header)
+		ctx, cancel := scrapeContext(r, test.offset)
+		deadline, ok := ctx.Deadline()
+		cancel()
+
+		if ok != (test.deadline != 0) {
+			t.Errorf("Wrong deadline presence for header [%s]", test.header)
+			continue
+		}
+		if left := time.Until(deadline); ok && (left > test.deadline || left < test.deadline-time.Second) {
+			t.Errorf("Wrong deadline for header [%s], %v left", test.header, left)
+		}
+	}
+}
+
+func TestMetricsHandlerTimeout(t *testing.T) {
+	// Instances of project-2 never come
+	redis := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "project-2") {
+			<-r.Context().Done()
+			return
+		}
+		json.NewEncoder(w).Encode(redisInstanceList{
+			Instances: []*redisInstance{{
+				Name:                  "projects/project-1/locations/us-central1/instances/cache",
+				TransitEncryptionMode: "SERVER_AUTHENTICATION",
+				ServerCaCerts:         []*redisTLSCertificate{{SerialNumber: "1", Cert: pemData}},
+			}},
+		})
+	}))
+	defer redis.Close()
+	defer func(p string) { redisBasePath = p }(redisBasePath)
+	redisBasePath = redis.URL + "/"
+
+	c := NewSSLCollector([]string{"project-1", "project-2"}, redis.Client(), false, WithServices([]string{"redis"}))
+	ts := httptest.NewServer(MetricsHandler(c, 100*time.Millisecond))
+	defer ts.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+	req.Header.Set(scrapeTimeoutHeader, "0.3")
+	start := time.Now()
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("Scrape took %v", time.Since(start))
+	}
+	for _, expected := range []string{
+		"gcp_ssl_scrape_timed_out 1",
+		`gcp_ssl_validity_seconds{name="us-central1-cache-1",project="project-1",service="redis"}`,
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("Expected [%s] within %s", expected, body)
+		}
+	}
+	// Partial scrapes don't count certificates as deleted
+	if len(c.rotations.certificates) != 0 {
+		t.Errorf("Rotations shouldn't be updated by partial scrapes %v", c.rotations.certificates)
+	}
+}
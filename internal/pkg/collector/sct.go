@@ -0,0 +1,171 @@
+package collector
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// Embedded signed certificate timestamp list extension, RFC 6962 section 3.3
+var sctListOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// Chrome and Apple CT policies require 2 SCTs from distinct logs up to 180 days of lifetime and 3 above
+const (
+	sctShortLifetime     = 180 * 24 * time.Hour
+	sctShortLifetimeLogs = 2
+	sctLongLifetimeLogs  = 3
+)
+
+// Verdicts of rotated away certificates are dropped all at once past this many
+const maxTrustVerdicts = 10000
+
+type certificateTransparency struct {
+	roots *x509.CertPool // Publicly trusted roots, system ones when nil
+
+	sctCount     *prometheus.Desc
+	sctCompliant *prometheus.Desc
+
+	mu       sync.Mutex
+	verdicts map[string]bool // By fingerprint of the certificate and its chain
+}
+
+func newCertificateTransparency(variableLabels []string) *certificateTransparency {
+	return &certificateTransparency{
+		sctCount: prometheus.NewDesc("gcp_ssl_sct_count",
+			"Number of signed certificate timestamps embedded in an ssl certificate",
+			variableLabels, nil),
+		sctCompliant: prometheus.NewDesc("gcp_ssl_sct_compliant",
+			"Whether a publicly trusted ssl certificate embeds enough SCTs from distinct logs for its lifetime",
+			variableLabels, nil),
+		verdicts: make(map[string]bool),
+	}
+}
+
+func (t *certificateTransparency) describe(ch chan<- *prometheus.Desc) {
+	ch <- t.sctCount
+	ch <- t.sctCompliant
+}
+
+// Compliance is only exported for publicly trusted certificates, browsers don't require SCTs otherwise
+func (t *certificateTransparency) collect(ch chan<- prometheus.Metric, cert *certificate) {
+	if cert.x509 == nil {
+		return
+	}
+	logIDs, err := parseSCTList(cert.x509)
+	if err != nil {
+		log.Errorf("Trying to parse SCT list of certificate [%s] in project [%s] with error [%s]", cert.name, cert.project, err)
+		return
+	}
+	labels := []string{cert.name, cert.project, cert.service}
+	ch <- prometheus.MustNewConstMetric(t.sctCount, prometheus.GaugeValue, float64(len(logIDs)), labels...)
+
+	if !t.publiclyTrusted(cert.x509, cert.chain) {
+		return
+	}
+	logs := make(map[string]bool)
+	for _, id := range logIDs {
+		logs[id] = true
+	}
+	ch <- prometheus.MustNewConstMetric(t.sctCompliant, prometheus.GaugeValue,
+		boolToFloat(len(logs) >= requiredSCTs(cert.x509)), labels...)
+}
+
+// Verified when the certificate was issued, so expired certificates are still known as publicly trusted.
+// Verdicts never change for a given chain, they are cached rather than verified on every scrape
+func (t *certificateTransparency) publiclyTrusted(leaf *x509.Certificate, chain []*x509.Certificate) bool {
+	fingerprints := []string{fingerprint(leaf)}
+	for _, c := range chain {
+		fingerprints = append(fingerprints, fingerprint(c))
+	}
+	key := strings.Join(fingerprints, ",")
+	t.mu.Lock()
+	trusted, ok := t.verdicts[key]
+	t.mu.Unlock()
+	if ok {
+		return trusted
+	}
+
+	trusted = t.verify(leaf, chain)
+	t.mu.Lock()
+	if len(t.verdicts) >= maxTrustVerdicts {
+		t.verdicts = make(map[string]bool)
+	}
+	t.verdicts[key] = trusted
+	t.mu.Unlock()
+	return trusted
+}
+
+func (t *certificateTransparency) verify(leaf *x509.Certificate, chain []*x509.Certificate) bool {
+	intermediates := x509.NewCertPool()
+	for _, c := range chain {
+		intermediates.AddCert(c)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         t.roots,
+		Intermediates: intermediates,
+		CurrentTime:   leaf.NotBefore,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err == nil
+}
+
+func requiredSCTs(c *x509.Certificate) int {
+	if c.NotAfter.Sub(c.NotBefore) <= sctShortLifetime {
+		return sctShortLifetimeLogs
+	}
+	return sctLongLifetimeLogs
+}
+
+// Returns the log ID of every SCT embedded in the certificate
+func parseSCTList(c *x509.Certificate) ([]string, error) {
+	var raw []byte
+	for _, ext := range c.Extensions {
+		if ext.Id.Equal(sctListOID) {
+			if _, err := asn1.Unmarshal(ext.Value, &raw); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	if raw == nil {
+		return nil, nil
+	}
+
+	list, err := readOpaque16(raw)
+	if err != nil {
+		return nil, err
+	}
+	var logIDs []string
+	for len(list) > 0 {
+		sct, err := readOpaque16(list)
+		if err != nil {
+			return nil, err
+		}
+		list = list[2+len(sct):]
+		// Version byte followed by the 32 bytes log ID
+		if len(sct) < 33 {
+			return nil, errors.New("SCT too short")
+		}
+		logIDs = append(logIDs, string(sct[1:33]))
+	}
+	return logIDs, nil
+}
+
+// Reads a TLS opaque vector with a 2 bytes length prefix
+func readOpaque16(b []byte) ([]byte, error) {
+	if len(b) < 2 {
+		return nil, errors.New("SCT list truncated")
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return nil, errors.New("SCT list truncated")
+	}
+	return b[2 : 2+n], nil
+}
@@ -0,0 +1,142 @@
+package collector
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Returns an SCT list extension with an SCT per log ID byte
+func newSCTListExtension(t *testing.T, logs ...byte) pkix.Extension {
+	opaque := func(b []byte) []byte {
+		n := make([]byte, 2)
+		binary.BigEndian.PutUint16(n, uint16(len(b)))
+		return append(n, b...)
+	}
+	var list []byte
+	for _, id := range logs {
+		sct := make([]byte, 1+32+8+2)
+		sct[1] = id
+		list = append(list, opaque(sct)...)
+	}
+	value, err := asn1.Marshal(opaque(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkix.Extension{Id: sctListOID, Value: value}
+}
+
+func TestCertificateTransparencyCollect(t *testing.T) {
+	root, rootKey := newTestCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test Root"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	untrusted, untrustedKey := newTestCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Private Root"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+
+	newLeaf := func(cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, logs ...byte) *certificate {
+		template := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+		if len(logs) > 0 {
+			template.ExtraExtensions = []pkix.Extension{newSCTListExtension(t, logs...)}
+		}
+		leaf, _ := newTestCertificate(t, template, parent, parentKey)
+		return &certificate{name: cn, project: "project-1", service: "compute", x509: leaf}
+	}
+
+	c := NewSSLCollector(nil, nil, false)
+	c.transparency.roots = x509.NewCertPool()
+	c.transparency.roots.AddCert(root)
+
+	tests := []struct {
+		cert      *certificate
+		count     float64
+		compliant float64 // -1 when not exported
+	}{
+		{newLeaf("compliant", root, rootKey, 1, 2), 2, 1},
+		{newLeaf("same-log", root, rootKey, 1, 1), 2, 0},
+		{newLeaf("none", root, rootKey), 0, 0},
+		{newLeaf("private", untrusted, untrustedKey, 1), 1, -1},
+	}
+
+	for _, test := range tests {
+		ch := make(chan prometheus.Metric, 10)
+		c.transparency.collect(ch, test.cert)
+		close(ch)
+
+		count, compliant := -1.0, -1.0
+		for m := range ch {
+			pb := &dto.Metric{}
+			if err := m.Write(pb); err != nil {
+				t.Fatal(err)
+			}
+			switch m.Desc() {
+			case c.transparency.sctCount:
+				count = pb.GetGauge().GetValue()
+			case c.transparency.sctCompliant:
+				compliant = pb.GetGauge().GetValue()
+			}
+		}
+		if count != test.count || compliant != test.compliant {
+			t.Errorf("Wrong SCT metrics for [%s], count %v compliant %v", test.cert.name, count, compliant)
+		}
+	}
+}
+
+func TestPubliclyTrustedCache(t *testing.T) {
+	root, rootKey := newTestCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test Root"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	intermediate, intermediateKey := newTestCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test Intermediate"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, root, rootKey)
+	leaf, _ := newTestCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "a.example.com"}}, intermediate, intermediateKey)
+
+	ct := newCertificateTransparency([]string{"name", "project", "service"})
+	ct.roots = x509.NewCertPool()
+	ct.roots.AddCert(root)
+	if ct.publiclyTrusted(leaf, nil) {
+		t.Error("Certificate without its intermediate shouldn't be publicly trusted")
+	}
+	if !ct.publiclyTrusted(leaf, []*x509.Certificate{intermediate}) {
+		t.Error("Certificate along its intermediate should be publicly trusted")
+	}
+
+	// Cached verdicts aren't verified again
+	ct.roots = x509.NewCertPool()
+	if !ct.publiclyTrusted(leaf, []*x509.Certificate{intermediate}) || len(ct.verdicts) != 2 {
+		t.Errorf("Expected cached verdicts, got %v", ct.verdicts)
+	}
+}
+
+func TestRequiredSCTs(t *testing.T) {
+	cert, err := parseCertificate(pemData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Valid for 3 months
+	if n := requiredSCTs(cert); n != 2 {
+		t.Errorf("Wrong number of required SCTs %d", n)
+	}
+	cert.NotAfter = cert.NotBefore.AddDate(1, 0, 0)
+	if n := requiredSCTs(cert); n != 3 {
+		t.Errorf("Wrong number of required SCTs %d", n)
+	}
+}
@@ -0,0 +1,236 @@
+package collector
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// WithStateFile persists the last certificates and rotation history into path, after a restart they are
+// served right away, marked as stale, while Warmup or the first scrape refreshes them in the background
+func WithStateFile(path string) Option {
+	return func(c *SSLCollector) {
+		if path == "" {
+			return
+		}
+		c.state = &stateStore{
+			path: path,
+			stale: prometheus.NewDesc("gcp_ssl_state_stale",
+				"Whether the certificates served were persisted before the exporter started",
+				nil, nil),
+			fetchedAt: prometheus.NewDesc("gcp_ssl_state_timestamp_seconds",
+				"Time the certificates served were fetched",
+				nil, nil),
+		}
+		if err := c.state.load(c.rotations); err != nil {
+			log.Warnf("Trying to load state file [%s] with error [%s]", path, err)
+		}
+	}
+}
+
+type stateStore struct {
+	path      string
+	stale     *prometheus.Desc
+	fetchedAt *prometheus.Desc
+
+	mu         sync.Mutex
+	persisted  []*certificate // Loaded from path, until the first refresh
+	lastFetch  time.Time
+	refreshing bool
+
+	writeMu sync.Mutex
+}
+
+type persistedState struct {
+	FetchedAt    time.Time              `json:"fetched_at"`
+	Certificates []persistedCertificate `json:"certificates"`
+	Rotations    []persistedRotation    `json:"rotations"`
+	Created      map[string]float64     `json:"created"`
+	Deleted      map[string]float64     `json:"deleted"`
+}
+
+type persistedCertificate struct {
+	Name     string   `json:"name"`
+	Project  string   `json:"project"`
+	Service  string   `json:"service"`
+	Instance string   `json:"instance,omitempty"`
+	Chain    [][]byte `json:"chain"` // DER encoded, leaf first
+}
+
+type persistedRotation struct {
+	Name         string    `json:"name"`
+	Project      string    `json:"project"`
+	Service      string    `json:"service"`
+	Fingerprint  string    `json:"fingerprint"`
+	Rotations    float64   `json:"rotations"`
+	LastRotation time.Time `json:"last_rotation"`
+}
+
+func (s *stateStore) describe(ch chan<- *prometheus.Desc) {
+	ch <- s.stale
+	ch <- s.fetchedAt
+}
+
+func (s *stateStore) collect(ch chan<- prometheus.Metric, stale bool) {
+	s.mu.Lock()
+	fetchedAt := s.lastFetch
+	s.mu.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(s.stale, prometheus.GaugeValue, boolToFloat(stale))
+	ch <- prometheus.MustNewConstMetric(s.fetchedAt, prometheus.GaugeValue, float64(fetchedAt.Unix()))
+}
+
+// Returns the persisted certificates with their expiry as of now, as long as no refresh completed
+func (s *stateStore) current() ([]*certificate, bool) {
+	if s == nil {
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.persisted == nil {
+		return nil, false
+	}
+	return withExpiryAsOf(s.persisted, time.Now()), true
+}
+
+// Fetches certificates in the background unless already doing so, they are persisted and replace the loaded ones
+func (s *stateStore) refresh(fetch func() ([]*certificate, error), rotations *rotationTracker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.refreshing {
+		return
+	}
+	s.refreshing = true
+
+	go func() {
+		certs, err := fetch()
+		s.mu.Lock()
+		s.refreshing = false
+		if err != nil {
+			s.mu.Unlock()
+			log.Errorf("Trying to refresh persisted certificates with error [%s]", err)
+			return
+		}
+		s.persisted = nil
+		s.mu.Unlock()
+		s.save(certs, rotations)
+	}()
+}
+
+func (s *stateStore) load(rotations *rotationTracker) error {
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var state persistedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	certs := []*certificate{}
+	for _, p := range state.Certificates {
+		chain, err := parseDERChain(p.Chain)
+		if err != nil {
+			e := fmt.Sprintf("Trying to parse persisted certificate [%s] in project [%s] with error [%s]", p.Name, p.Project, err)
+			return errors.New(e)
+		}
+		certs = append(certs, &certificate{
+			name:     p.Name,
+			project:  p.Project,
+			service:  p.Service,
+			instance: p.Instance,
+			x509:     chain[0],
+			chain:    chain[1:],
+		})
+	}
+
+	s.mu.Lock()
+	s.persisted = certs
+	s.lastFetch = state.FetchedAt
+	s.mu.Unlock()
+	rotations.restore(state)
+	return nil
+}
+
+// Written into a temporary file renamed over path, so a crash never leaves it truncated
+func (s *stateStore) save(certs []*certificate, rotations *rotationTracker) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	now := time.Now()
+	s.mu.Lock()
+	s.lastFetch = now
+	s.mu.Unlock()
+
+	state := rotations.persist()
+	state.FetchedAt = now
+	state.Certificates = []persistedCertificate{}
+	for _, cert := range certs {
+		if cert.x509 == nil {
+			continue
+		}
+		p := persistedCertificate{
+			Name:     cert.name,
+			Project:  cert.project,
+			Service:  cert.service,
+			Instance: cert.instance,
+			Chain:    [][]byte{cert.x509.Raw},
+		}
+		for _, c := range cert.chain {
+			p.Chain = append(p.Chain, c.Raw)
+		}
+		state.Certificates = append(state.Certificates, p)
+	}
+
+	if err := writeFileAtomically(s.path, state); err != nil {
+		log.Errorf("Trying to save state file [%s] with error [%s]", s.path, err)
+	}
+}
+
+func writeFileAtomically(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func parseDERChain(ders [][]byte) ([]*x509.Certificate, error) {
+	if len(ders) == 0 {
+		return nil, errors.New("Empty certificate chain")
+	}
+	var chain []*x509.Certificate
+	for _, der := range ders {
+		c, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, c)
+	}
+	return chain, nil
+}
@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStateStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	root, intermediate, leaves := newTestChain(t, "a.example.com", "renewed-a.example.com")
+	certs := []*certificate{
+		{name: "a", project: "project-1", service: "compute", x509: leaves[0], chain: []*x509.Certificate{intermediate, root}},
+	}
+	rotations := newRotationTracker([]string{"name", "project", "service"})
+	rotations.update(certs, time.Unix(1000, 0))
+	certs[0].x509 = leaves[1]
+	rotations.update(certs, time.Unix(2000, 0))
+
+	c := NewSSLCollector(nil, nil, false, WithStateFile(path))
+	if _, stale := c.state.current(); stale {
+		t.Fatal("Nothing should be served without a state file")
+	}
+	c.state.save(certs, rotations)
+
+	// Nothing is refreshed until Warmup or a scrape, once the config file is applied
+	c = NewSSLCollector(nil, nil, false, WithStateFile(path))
+	time.Sleep(50 * time.Millisecond)
+	if _, stale := c.state.current(); !stale {
+		t.Fatal("Persisted certificates shouldn't be refreshed by the constructor")
+	}
+
+	// Warm restart
+	c = NewSSLCollector(nil, nil, false, func(c *SSLCollector) { c.state = &stateStore{path: path} })
+	if err := c.state.load(c.rotations); err != nil {
+		t.Fatal(err)
+	}
+	persisted, stale := c.state.current()
+	if !stale || len(persisted) != 1 || persisted[0].name != "a" || len(persisted[0].chain) != 2 ||
+		fingerprint(persisted[0].x509) != fingerprint(leaves[1]) || persisted[0].secondsToExpire <= 0 {
+		t.Fatalf("Wrong persisted certificates %+v", persisted)
+	}
+	tracked := c.rotations.certificates[certificateKey{"a", "project-1", "compute"}]
+	if tracked == nil || tracked.rotations != 1 || tracked.lastRotation.Unix() != 2000 {
+		t.Errorf("Wrong persisted rotations %+v", tracked)
+	}
+
+	// Refreshed certificates replace the persisted ones
+	done := make(chan struct{})
+	c.state.refresh(func() ([]*certificate, error) {
+		defer close(done)
+		return nil, nil
+	}, c.rotations)
+	<-done
+	for i := 0; i < 100; i++ {
+		if _, stale = c.state.current(); !stale {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stale {
+		t.Error("Persisted certificates should no longer be served after a refresh")
+	}
+}
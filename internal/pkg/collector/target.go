@@ -0,0 +1,95 @@
+package collector
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// ProbeHandler serves the certificates of the project given per request, in the style of blackbox_exporter,
+// services default to the given ones and can be chosen per request as a comma separated list
+func ProbeHandler(client *http.Client, onlyInUse bool, services []string, offset time.Duration, opts ...Option) http.Handler {
+	// Built once so the OCSP and CRL caches are kept between requests
+	shared := NewSSLCollector(nil, client, onlyInUse, append(opts, withFetchErrors())...)
+	trackers := &probeTrackers{trackers: make(map[string]*probeTracker)}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		project := r.URL.Query().Get("project")
+		if project == "" {
+			http.Error(w, "project parameter is missing", http.StatusBadRequest)
+			return
+		}
+		targetServices := services
+		if s := r.URL.Query().Get("service"); s != "" {
+			targetServices = strings.Split(s, ",")
+		}
+
+		ctx, cancel := scrapeContext(r, offset)
+		defer cancel()
+
+		tracker := trackers.get(project + "/" + strings.Join(targetServices, ","))
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(&scrapeCollector{
+			collector: shared.forProbe(project, targetServices, tracker),
+			ctx:       ctx,
+		})
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	})
+}
+
+// Maximum number of rotation trackers kept for /probe, as projects and services come from requests
+const maxProbeTrackers = 1000
+
+// Rotation trackers of /probe by project and services, the least recently used one is evicted beyond maxProbeTrackers
+type probeTrackers struct {
+	mu       sync.Mutex
+	trackers map[string]*probeTracker
+}
+
+type probeTracker struct {
+	tracker *rotationTracker
+	used    time.Time
+}
+
+func (p *probeTrackers) get(key string) *rotationTracker {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if t, ok := p.trackers[key]; ok {
+		t.used = time.Now()
+		return t.tracker
+	}
+	if len(p.trackers) >= maxProbeTrackers {
+		oldest := ""
+		for k, t := range p.trackers {
+			if oldest == "" || t.used.Before(p.trackers[oldest].used) {
+				oldest = k
+			}
+		}
+		delete(p.trackers, oldest)
+	}
+	t := &probeTracker{tracker: newRotationTracker([]string{"name", "project", "service"}), used: time.Now()}
+	p.trackers[key] = t
+	return t.tracker
+}
+
+// Returns a collector fetching from the project and services of a probe, rotations being tracked by tracker
+func (c *SSLCollector) forProbe(project string, services []string, tracker *rotationTracker) *SSLCollector {
+	view := *c
+	view.projects = []string{project}
+	if len(services) > 0 {
+		view.services = services
+	}
+	view.rotations = tracker
+	return &view
+}
+
+// Failing to fetch certificates fails the whole scrape, so the target is seen down
+func withFetchErrors() Option {
+	return func(c *SSLCollector) {
+		c.fetchErrors = true
+	}
+}
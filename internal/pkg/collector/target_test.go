@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProbeHandler(t *testing.T) {
+	redis := newRedisTestServer(t)
+	defer redis.Close()
+	defer func(p string) { redisBasePath = p }(redisBasePath)
+	redisBasePath = redis.URL + "/"
+
+	ts := httptest.NewServer(ProbeHandler(redis.Client(), false, []string{"compute"}, 0))
+	defer ts.Close()
+
+	tests := []struct {
+		query    string
+		status   int
+		contains string
+	}{
+		{"", http.StatusBadRequest, "project parameter is missing"},
+		{"?project=project-1&service=redis", http.StatusOK,
+			`gcp_ssl_validity_seconds{name="us-central1-cache-1",project="project-1",service="redis"}`},
+		{"?project=project-1&service=redis,unknown", http.StatusInternalServerError, "Unknown service [unknown]"},
+	}
+
+	for _, test := range tests {
+		res, err := http.Get(ts.URL + test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+
+		if res.StatusCode != test.status || !strings.Contains(string(body), test.contains) {
+			t.Errorf("Wrong response for [%s] %d %s", test.query, res.StatusCode, body)
+		}
+	}
+}
+
+func TestProbeTrackers(t *testing.T) {
+	p := &probeTrackers{trackers: make(map[string]*probeTracker)}
+	first := p.get("project-0/redis")
+	for i := 1; i < maxProbeTrackers; i++ {
+		p.get(fmt.Sprintf("project-%d/redis", i))
+	}
+	p.trackers["project-1/redis"].used = time.Time{}
+
+	if p.get("project-0/redis") != first {
+		t.Errorf("Trackers should be reused")
+	}
+	p.get("project-new/redis")
+	if len(p.trackers) != maxProbeTrackers {
+		t.Errorf("Expected %d trackers, got %d", maxProbeTrackers, len(p.trackers))
+	}
+	if _, ok := p.trackers["project-1/redis"]; ok {
+		t.Errorf("The least recently used tracker should be evicted")
+	}
+}
@@ -0,0 +1,407 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/snebel29/prometheus-gcp-ssl-exporter/internal/pkg/config"
+)
+
+// Base path of the Resource Manager API used to discover projects, which has no client within the vendored google-api-go-client
+var resourceManagerBasePath = "https://cloudresourcemanager.googleapis.com/v1/"
+
+// Projects selected by the config file, swapped as a whole on every reload
+type configuredTargets struct {
+	mu  sync.RWMutex
+	set *targetSet
+}
+
+type targetSet struct {
+	static      []*target
+	rules       []*discoveryRule
+	labelNames  []string
+	projectInfo *prometheus.Desc
+}
+
+type targetSettings struct {
+	source          config.TargetConfig // As given within the config file
+	services        []string
+	labels          map[string]string
+	include         []*regexp.Regexp
+	exclude         []*regexp.Regexp
+	refreshInterval time.Duration
+	client          *http.Client
+}
+
+type target struct {
+	project string
+	targetSettings
+
+	mu      sync.Mutex
+	fetched time.Time
+	certs   []*certificate
+}
+
+type discoveryRule struct {
+	filter   string
+	basePath string // Of the Resource Manager API
+	settings targetSettings
+
+	mu      sync.Mutex
+	listed  time.Time
+	targets map[string]*target // By project
+}
+
+type resourceManagerProjectList struct {
+	Projects []struct {
+		ProjectID      string `json:"projectId"`
+		LifecycleState string `json:"lifecycleState"`
+	} `json:"projects"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// ApplyConfig replaces the projects to fetch certificates from by those within cfg, nothing is replaced
+// unless every service is known and every credentials file is valid. Projects and discovery rules whose
+// settings didn't change keep their certificates and listed projects until their refresh interval
+func (c *SSLCollector) ApplyConfig(cfg *config.Config) error {
+	set := &targetSet{}
+	labelNames := make(map[string]bool)
+
+	previousTargets := make(map[string]*target)
+	previousRules := make(map[string]*discoveryRule)
+	if previous := c.configured.current(); previous != nil {
+		for _, t := range previous.static {
+			previousTargets[t.project] = t
+		}
+		for _, rule := range previous.rules {
+			previousRules[rule.filter] = rule
+		}
+	}
+
+	for _, p := range cfg.Projects {
+		settings, err := c.newTargetSettings(&p.TargetConfig)
+		if err != nil {
+			e := fmt.Sprintf("Trying to apply config of project [%s] with error [%s]", p.Project, err)
+			return errors.New(e)
+		}
+		t := &target{project: p.Project, targetSettings: settings}
+		if previous, ok := previousTargets[p.Project]; ok && reflect.DeepEqual(previous.source, settings.source) {
+			previous.carryOver(t)
+		}
+		set.static = append(set.static, t)
+		for name := range p.Labels {
+			labelNames[name] = true
+		}
+	}
+	for _, d := range cfg.Discovery {
+		settings, err := c.newTargetSettings(&d.TargetConfig)
+		if err != nil {
+			e := fmt.Sprintf("Trying to apply config of discovery rule [%s] with error [%s]", d.Filter, err)
+			return errors.New(e)
+		}
+		rule := &discoveryRule{
+			filter:   d.Filter,
+			basePath: c.apiBasePath("cloudresourcemanager"),
+			settings: settings,
+			targets:  make(map[string]*target),
+		}
+		if previous, ok := previousRules[d.Filter]; ok && reflect.DeepEqual(previous.settings.source, settings.source) {
+			previous.carryOver(rule)
+		}
+		set.rules = append(set.rules, rule)
+		for name := range d.Labels {
+			labelNames[name] = true
+		}
+	}
+
+	for name := range labelNames {
+		set.labelNames = append(set.labelNames, name)
+	}
+	sort.Strings(set.labelNames)
+	set.projectInfo = prometheus.NewDesc("gcp_ssl_project_info",
+		"Labels given to a project within the config file",
+		append([]string{"project"}, set.labelNames...), nil)
+
+	c.configured.mu.Lock()
+	c.configured.set = set
+	c.configured.mu.Unlock()
+	return nil
+}
+
+// Hands the certificates fetched so far to next, which replaces t
+func (t *target) carryOver(next *target) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	next.fetched = t.fetched
+	next.certs = t.certs
+}
+
+// Hands the projects listed so far to next, which replaces r, along with their certificates
+func (r *discoveryRule) carryOver(next *discoveryRule) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	next.listed = r.listed
+	for project, t := range r.targets {
+		next.targets[project] = &target{project: project, targetSettings: next.settings}
+		t.carryOver(next.targets[project])
+	}
+}
+
+func compileAll(exprs []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+func (c *SSLCollector) newTargetSettings(cfg *config.TargetConfig) (targetSettings, error) {
+	settings := targetSettings{
+		source:          *cfg,
+		services:        c.services,
+		labels:          cfg.Labels,
+		refreshInterval: time.Duration(cfg.RefreshInterval),
+		client:          c.httpClient,
+	}
+	if len(cfg.Services) > 0 {
+		settings.services = cfg.Services
+	}
+	fetchers := c.fetchers()
+	for _, service := range settings.services {
+		if _, ok := fetchers[service]; !ok {
+			e := fmt.Sprintf("Unknown service [%s]", service)
+			return settings, errors.New(e)
+		}
+	}
+	var err error
+	if settings.include, err = compileAll(cfg.Include); err != nil {
+		return settings, err
+	}
+	if settings.exclude, err = compileAll(cfg.Exclude); err != nil {
+		return settings, err
+	}
+	if cfg.CredentialsFile != "" {
+		client, err := newCredentialsFileClient(c.oauth2Context(), cfg.CredentialsFile)
+		if err != nil {
+			return settings, err
+		}
+		settings.client = client
+	}
+	if cfg.ImpersonateServiceAccount != "" {
+		settings.client = newImpersonatedClient(c.oauth2Context(), settings.client, c.apiBasePath("iamcredentials"), cfg.ImpersonateServiceAccount, cfg.Delegates)
+	}
+	return settings, nil
+}
+
+func (t *configuredTargets) current() *targetSet {
+	if t == nil {
+		return nil
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.set
+}
+
+// Static projects come first, those also discovered are only fetched once
+func (s *targetSet) targets(ctx context.Context) []*target {
+	if s == nil {
+		return nil
+	}
+	targets := append([]*target{}, s.static...)
+	seen := make(map[string]bool)
+	for _, t := range s.static {
+		seen[t.project] = true
+	}
+	for _, rule := range s.rules {
+		for _, t := range rule.discover(ctx) {
+			if !seen[t.project] {
+				seen[t.project] = true
+				targets = append(targets, t)
+			}
+		}
+	}
+	return targets
+}
+
+func (s *targetSet) collect(ch chan<- prometheus.Metric, targets []*target) {
+	for _, t := range targets {
+		values := []string{t.project}
+		for _, name := range s.labelNames {
+			values = append(values, t.labels[name])
+		}
+		ch <- prometheus.MustNewConstMetric(s.projectInfo, prometheus.GaugeValue, 1, values...)
+	}
+}
+
+// Whether every discovery rule listed projects at least once
+func (s *targetSet) discovered() bool {
+	if s == nil {
+		return true
+	}
+	for _, rule := range s.rules {
+		rule.mu.Lock()
+		listed := !rule.listed.IsZero()
+		rule.mu.Unlock()
+		if !listed {
+			return false
+		}
+	}
+	return true
+}
+
+// Clients of the targets and discovery rules, each once
+func (s *targetSet) clients() []*http.Client {
+	if s == nil {
+		return nil
+	}
+	var clients []*http.Client
+	seen := make(map[*http.Client]bool)
+	add := func(client *http.Client) {
+		if !seen[client] {
+			seen[client] = true
+			clients = append(clients, client)
+		}
+	}
+	for _, t := range s.static {
+		add(t.client)
+	}
+	for _, rule := range s.rules {
+		add(rule.settings.client)
+	}
+	return clients
+}
+
+// Projects are listed again once older than the refresh interval, the last ones are kept when listing fails
+func (r *discoveryRule) discover(ctx context.Context) []*target {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.listed.IsZero() || time.Since(r.listed) >= r.settings.refreshInterval {
+		projects, err := listProjects(ctx, r.settings.client, r.basePath, r.filter)
+		if err != nil {
+			log.Errorf("Trying to discover projects with filter [%s] with error [%s]", r.filter, err)
+		} else {
+			targets := make(map[string]*target)
+			for _, project := range projects {
+				if t, ok := r.targets[project]; ok {
+					targets[project] = t
+				} else {
+					targets[project] = &target{project: project, targetSettings: r.settings}
+				}
+			}
+			r.targets = targets
+			r.listed = time.Now()
+		}
+	}
+
+	var targets []*target
+	for _, t := range r.targets {
+		targets = append(targets, t)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].project < targets[j].project })
+	return targets
+}
+
+func listProjects(ctx context.Context, client *http.Client, basePath, filter string) ([]string, error) {
+	var projects []string
+	pageToken := ""
+	for {
+		u := fmt.Sprintf("%sprojects?filter=%s&pageToken=%s",
+			basePath, url.QueryEscape(filter), url.QueryEscape(pageToken))
+
+		var list resourceManagerProjectList
+		if err := getJSON(ctx, client, u, &list); err != nil {
+			return nil, err
+		}
+		for _, p := range list.Projects {
+			if p.LifecycleState == "ACTIVE" {
+				projects = append(projects, p.ProjectID)
+			}
+		}
+
+		if list.NextPageToken == "" {
+			return projects, nil
+		}
+		pageToken = list.NextPageToken
+	}
+}
+
+// Certificates are fetched again once older than the refresh interval, meanwhile the last ones are returned
+func (t *target) fetch(ctx context.Context, c *SSLCollector) ([]*certificate, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.certs != nil && time.Since(t.fetched) < t.refreshInterval {
+		return withExpiryAsOf(t.certs, time.Now()), nil
+	}
+
+	certs, err := c.forTarget(t).fetchFromGCP(ctx)
+	certs = t.filter(certs)
+	if err != nil {
+		return certs, err
+	}
+	t.certs = certs
+	t.fetched = time.Now()
+	return certs, nil
+}
+
+func (t *target) filter(certs []*certificate) []*certificate {
+	filtered := []*certificate{}
+	for _, cert := range certs {
+		if t.keeps(cert.name) {
+			filtered = append(filtered, cert)
+		}
+	}
+	return filtered
+}
+
+func (t *target) keeps(name string) bool {
+	for _, re := range t.exclude {
+		if re.MatchString(name) {
+			return false
+		}
+	}
+	if len(t.include) == 0 {
+		return true
+	}
+	for _, re := range t.include {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns a collector fetching from the target project and services only, with its own client
+func (c *SSLCollector) forTarget(t *target) *SSLCollector {
+	view := *c
+	view.configured = nil
+	view.projects = []string{t.project}
+	view.services = t.services
+	view.httpClient = t.client
+	return &view
+}
+
+// Returns copies of certs with their expiry computed as of now
+func withExpiryAsOf(certs []*certificate, now time.Time) []*certificate {
+	copies := make([]*certificate, len(certs))
+	for i, cert := range certs {
+		c := *cert
+		c.secondsToExpire = float64(c.x509.NotAfter.Unix() - now.Unix())
+		copies[i] = &c
+	}
+	return copies
+}
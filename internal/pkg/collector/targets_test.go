@@ -0,0 +1,235 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"github.com/snebel29/prometheus-gcp-ssl-exporter/internal/pkg/config"
+)
+
+func newResourceManagerTestServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/projects" || r.URL.Query().Get("filter") != "labels.env:prod" {
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+		var list resourceManagerProjectList
+		json.Unmarshal([]byte(`{"projects": [
+			{"projectId": "project-1", "lifecycleState": "ACTIVE"},
+			{"projectId": "project-2", "lifecycleState": "DELETE_REQUESTED"}
+		]}`), &list)
+		json.NewEncoder(w).Encode(list)
+	}))
+}
+
+func TestApplyConfig(t *testing.T) {
+	var requests int32
+	redis := newRedisTestServer(t)
+	defer redis.Close()
+	counting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		redis.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer counting.Close()
+	defer func(p string) { redisBasePath = p }(redisBasePath)
+	redisBasePath = counting.URL + "/"
+
+	rm := newResourceManagerTestServer(t)
+	defer rm.Close()
+	defer func(p string) { resourceManagerBasePath = p }(resourceManagerBasePath)
+	resourceManagerBasePath = rm.URL + "/"
+
+	c := NewSSLCollector(nil, http.DefaultClient, false)
+	cfg := &config.Config{Discovery: []*config.DiscoveryConfig{{
+		Filter: "labels.env:prod",
+		TargetConfig: config.TargetConfig{
+			Services:        []string{"redis"},
+			Labels:          map[string]string{"team": "cache"},
+			Exclude:         []string{"^europe-"},
+			RefreshInterval: model.Duration(time.Hour),
+		},
+	}}}
+	if err := c.ApplyConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		certs, err := c.fetchConfigured(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(certs) != 2 || certs[0].name != "us-central1-cache-1" || certs[1].name != "us-central1-cache-2" {
+			t.Errorf("Wrong certificates fetched %v", certs)
+		}
+	}
+	// Both pages are fetched once, then served until the refresh interval
+	if requests != 2 {
+		t.Errorf("Expected 2 redis requests, got %d", requests)
+	}
+
+	// Reloading the same settings keeps the certificates, changing them fetches again
+	for i, exclude := range []string{"^europe-", "-2$"} {
+		cfg.Discovery[0].Exclude = []string{exclude}
+		if err := c.ApplyConfig(cfg); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.fetchConfigured(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if expected := int32(2 + 2*i); requests != expected {
+			t.Errorf("Expected %d redis requests after reloading, got %d", expected, requests)
+		}
+	}
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.configured.current().collect(ch, c.configured.current().targets(context.Background()))
+		close(ch)
+	}()
+	var infos []string
+	for m := range ch {
+		infos = append(infos, m.Desc().String())
+	}
+	if len(infos) != 1 || !strings.Contains(infos[0], "gcp_ssl_project_info") || !strings.Contains(infos[0], "team") {
+		t.Errorf("Wrong project info %v", infos)
+	}
+}
+
+func TestDiscoveryOncePerScrape(t *testing.T) {
+	redis := newRedisTestServer(t)
+	defer redis.Close()
+	defer func(p string) { redisBasePath = p }(redisBasePath)
+	redisBasePath = redis.URL + "/"
+
+	var listings int32
+	rm := newResourceManagerTestServer(t)
+	defer rm.Close()
+	counting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&listings, 1)
+		rm.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer counting.Close()
+	defer func(p string) { resourceManagerBasePath = p }(resourceManagerBasePath)
+	resourceManagerBasePath = counting.URL + "/"
+
+	// Project info and the features querying GCP on their own need the discovered targets too
+	c := NewSSLCollector(nil, http.DefaultClient, false)
+	cfg := &config.Config{Discovery: []*config.DiscoveryConfig{{
+		Filter:       "labels.env:prod",
+		TargetConfig: config.TargetConfig{Services: []string{"redis"}},
+	}}}
+	if err := c.ApplyConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.collect(context.Background(), ch)
+		close(ch)
+	}()
+	for range ch {
+	}
+	if listings != 1 {
+		t.Errorf("Projects should be listed once per scrape, got %d listings", listings)
+	}
+}
+
+func TestApplyConfigInvalid(t *testing.T) {
+	c := NewSSLCollector(nil, http.DefaultClient, false)
+	tests := []struct {
+		target config.TargetConfig
+		err    string
+	}{
+		{config.TargetConfig{Services: []string{"unknown"}}, "Unknown service [unknown]"},
+		{config.TargetConfig{CredentialsFile: "/nonexistent"}, "no such file"},
+	}
+
+	for _, test := range tests {
+		cfg := &config.Config{Projects: []*config.ProjectConfig{{Project: "project-1", TargetConfig: test.target}}}
+		if err := c.ApplyConfig(cfg); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Expected error [%s], got [%v]", test.err, err)
+		}
+		if c.configured.current() != nil {
+			t.Errorf("Invalid configs shouldn't be applied")
+		}
+	}
+}
+
+func TestTargetKeeps(t *testing.T) {
+	include, _ := compileAll([]string{"^prod-"})
+	exclude, _ := compileAll([]string{"-old$"})
+	tg := &target{targetSettings: targetSettings{include: include, exclude: exclude}}
+
+	for name, expected := range map[string]bool{
+		"prod-web":     true,
+		"prod-web-old": false,
+		"staging-web":  false,
+	} {
+		if tg.keeps(name) != expected {
+			t.Errorf("Expected keeps(%s) to be %v", name, expected)
+		}
+	}
+}
+
+func TestConfigReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yml")
+
+	c := NewSSLCollector(nil, http.DefaultClient, false)
+	r := NewConfigReloader(path, c)
+	ts := httptest.NewServer(r.Handler())
+	defer ts.Close()
+
+	ioutil.WriteFile(path, []byte("projects:\n- project: project-1\n"), 0600)
+	res, err := http.Post(ts.URL, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK || gaugeValue(t, r.lastReloadSuccessful) != 1 {
+		t.Errorf("Expected a successful reload, got %d", res.StatusCode)
+	}
+	applied := c.configured.current()
+
+	ioutil.WriteFile(path, []byte("projects:\n- project: project-1\n  services: [unknown]\n"), 0600)
+	res, err = http.Post(ts.URL, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusInternalServerError || gaugeValue(t, r.lastReloadSuccessful) != 0 {
+		t.Errorf("Expected a failed reload, got %d", res.StatusCode)
+	}
+	if c.configured.current() != applied {
+		t.Errorf("Failed reloads should keep the last applied config")
+	}
+
+	res, err = http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected GET to be refused, got %d", res.StatusCode)
+	}
+}
+
+func gaugeValue(t *testing.T, g prometheus.Gauge) float64 {
+	pb := &dto.Metric{}
+	if err := g.Write(pb); err != nil {
+		t.Fatal(err)
+	}
+	return pb.GetGauge().GetValue()
+}
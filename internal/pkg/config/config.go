@@ -0,0 +1,131 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+// Config describes the projects to fetch certificates from, read from --config.file
+type Config struct {
+	// Default every project and discovery rule inherits unless they set their own
+	RefreshInterval model.Duration `yaml:"refresh_interval,omitempty"`
+
+	Projects  []*ProjectConfig   `yaml:"projects,omitempty"`
+	Discovery []*DiscoveryConfig `yaml:"discovery,omitempty"`
+}
+
+// ProjectConfig selects a single project
+type ProjectConfig struct {
+	Project      string `yaml:"project"`
+	TargetConfig `yaml:",inline"`
+}
+
+// DiscoveryConfig selects every project the Resource Manager lists for filter,
+// following its syntax such as "labels.env:prod"
+type DiscoveryConfig struct {
+	Filter       string `yaml:"filter"`
+	TargetConfig `yaml:",inline"`
+}
+
+// TargetConfig holds the settings of every selected project
+type TargetConfig struct {
+	// Services to fetch certificates from, the --service ones when empty
+	Services []string `yaml:"services,omitempty"`
+	// Exported as gcp_ssl_project_info labels
+	Labels map[string]string `yaml:"labels,omitempty"`
+	// Regular expressions certificate names must match and must not match
+	Include []string `yaml:"include,omitempty"`
+	Exclude []string `yaml:"exclude,omitempty"`
+	// Certificates are fetched again once older than this, on every scrape when zero
+	RefreshInterval model.Duration `yaml:"refresh_interval,omitempty"`
+	// Service account key used instead of the application default credentials
+	CredentialsFile string `yaml:"credentials_file,omitempty"`
+	// Service account impersonated through the IAM Credentials API by the credentials above,
+	// through every delegate in turn when given
+	ImpersonateServiceAccount string   `yaml:"impersonate_service_account,omitempty"`
+	Delegates                 []string `yaml:"delegates,omitempty"`
+}
+
+// Load reads and validates the config file in path
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		e := fmt.Sprintf("Trying to parse config file [%s] with error [%s]", path, err)
+		return nil, errors.New(e)
+	}
+	if err := cfg.Validate(); err != nil {
+		e := fmt.Sprintf("Trying to validate config file [%s] with error [%s]", path, err)
+		return nil, errors.New(e)
+	}
+	return cfg, nil
+}
+
+// Validate checks the config and fills every refresh interval left out with the default one
+func (cfg *Config) Validate() error {
+	if len(cfg.Projects) == 0 && len(cfg.Discovery) == 0 {
+		return errors.New("No projects nor discovery rules")
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range cfg.Projects {
+		if p.Project == "" {
+			return errors.New("Project without id")
+		}
+		if seen[p.Project] {
+			e := fmt.Sprintf("Project [%s] given more than once", p.Project)
+			return errors.New(e)
+		}
+		seen[p.Project] = true
+		if err := cfg.validateTarget(&p.TargetConfig); err != nil {
+			e := fmt.Sprintf("Project [%s]: %s", p.Project, err)
+			return errors.New(e)
+		}
+	}
+	for _, d := range cfg.Discovery {
+		if d.Filter == "" {
+			return errors.New("Discovery rule without filter")
+		}
+		if err := cfg.validateTarget(&d.TargetConfig); err != nil {
+			e := fmt.Sprintf("Discovery rule [%s]: %s", d.Filter, err)
+			return errors.New(e)
+		}
+	}
+	return nil
+}
+
+func (cfg *Config) validateTarget(t *TargetConfig) error {
+	for name := range t.Labels {
+		if !model.LabelName(name).IsValid() || name == "project" {
+			e := fmt.Sprintf("Invalid label name [%s]", name)
+			return errors.New(e)
+		}
+	}
+	for _, expr := range append(t.Include, t.Exclude...) {
+		if _, err := regexp.Compile(expr); err != nil {
+			return err
+		}
+	}
+	if len(t.Delegates) > 0 && t.ImpersonateServiceAccount == "" {
+		return errors.New("Delegates without a service account to impersonate")
+	}
+	for _, sa := range append([]string{t.ImpersonateServiceAccount}, t.Delegates...) {
+		if sa != "" && !strings.Contains(sa, "@") {
+			e := fmt.Sprintf("Invalid service account email [%s]", sa)
+			return errors.New(e)
+		}
+	}
+	if t.RefreshInterval == 0 {
+		t.RefreshInterval = cfg.RefreshInterval
+	}
+	return nil
+}
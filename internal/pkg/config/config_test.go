@@ -0,0 +1,88 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.yml")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, `
+refresh_interval: 5m
+projects:
+- project: project-1
+  services: [compute, redis]
+  labels:
+    team: web
+  exclude: ["^test-"]
+  refresh_interval: 1m
+discovery:
+- filter: labels.env:prod
+  credentials_file: /etc/key.json
+  impersonate_service_account: prod@p.iam.gserviceaccount.com
+  delegates: [exporter@p.iam.gserviceaccount.com]
+`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := cfg.Projects[0]
+	if p.Project != "project-1" || len(p.Services) != 2 || p.Labels["team"] != "web" || p.Exclude[0] != "^test-" {
+		t.Errorf("Wrong project config %+v", p)
+	}
+	if time.Duration(p.RefreshInterval) != time.Minute {
+		t.Errorf("Project refresh interval shouldn't be overridden, got %v", p.RefreshInterval)
+	}
+	d := cfg.Discovery[0]
+	if d.Filter != "labels.env:prod" || d.CredentialsFile != "/etc/key.json" ||
+		d.ImpersonateServiceAccount != "prod@p.iam.gserviceaccount.com" || len(d.Delegates) != 1 {
+		t.Errorf("Wrong discovery config %+v", d)
+	}
+	if time.Duration(d.RefreshInterval) != 5*time.Minute {
+		t.Errorf("Discovery refresh interval should default to the global one, got %v", d.RefreshInterval)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		content string
+		err     string
+	}{
+		{"projects: []", "No projects nor discovery rules"},
+		{"projects:\n- services: [compute]", "Project without id"},
+		{"projects:\n- project: p\n- project: p", "Project [p] given more than once"},
+		{"discovery:\n- services: [compute]", "Discovery rule without filter"},
+		{"projects:\n- project: p\n  labels:\n    project: x", "Invalid label name [project]"},
+		{"projects:\n- project: p\n  labels:\n    0team: x", "Invalid label name [0team]"},
+		{"projects:\n- project: p\n  include: [\"(\"]", "error parsing regexp"},
+		{"projects:\n- project: p\n  unknown: true", "field unknown not found"},
+		{"projects:\n- project: p\n  delegates: [d@p.iam.gserviceaccount.com]", "Delegates without a service account to impersonate"},
+		{"projects:\n- project: p\n  impersonate_service_account: target", "Invalid service account email [target]"},
+	}
+
+	for _, test := range tests {
+		path := writeConfig(t, test.content)
+		_, err := Load(path)
+		os.RemoveAll(filepath.Dir(path))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Expected error [%s] for %q, got [%v]", test.err, test.content, err)
+		}
+	}
+}
@@ -0,0 +1,367 @@
+package web
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v2"
+)
+
+// Config follows the web config file of Prometheus exporters, read from --web.config.file
+type Config struct {
+	TLSConfig  TLSConfig  `yaml:"tls_server_config"`
+	HTTPConfig HTTPConfig `yaml:"http_server_config"`
+	// bcrypt hashes of the passwords by user name
+	Users map[string]string `yaml:"basic_auth_users"`
+	// bcrypt hashes of the tokens accepted within an "Authorization: Bearer" header, not part of the
+	// Prometheus web config but for scrapers which can't do basic auth
+	BearerTokens []string `yaml:"bearer_tokens"`
+}
+
+// TLSConfig serves HTTPS when a certificate is given, verifying client certificates against ClientCAs as ClientAuth says
+type TLSConfig struct {
+	CertFile                 string   `yaml:"cert_file"`
+	KeyFile                  string   `yaml:"key_file"`
+	ClientAuth               string   `yaml:"client_auth_type"`
+	ClientCAs                string   `yaml:"client_ca_file"`
+	MinVersion               string   `yaml:"min_version"`
+	MaxVersion               string   `yaml:"max_version"`
+	CipherSuites             []string `yaml:"cipher_suites"`
+	PreferServerCipherSuites bool     `yaml:"prefer_server_cipher_suites"`
+	CurvePreferences         []string `yaml:"curve_preferences"`
+}
+
+// HTTPConfig sets response headers and whether HTTP/2 is offered over TLS
+type HTTPConfig struct {
+	HTTP2  *bool             `yaml:"http2"`
+	Header map[string]string `yaml:"headers"`
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"":                           tls.NoClientCert,
+	"NoClientCert":               tls.NoClientCert,
+	"RequestClientCert":          tls.RequestClientCert,
+	"RequireAnyClientCert":       tls.RequireAnyClientCert,
+	"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+	"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+}
+
+var tlsVersions = map[string]uint16{
+	"TLS10": tls.VersionTLS10,
+	"TLS11": tls.VersionTLS11,
+	"TLS12": tls.VersionTLS12,
+	"TLS13": 0x0304, // tls.VersionTLS13 from Go 1.12 onwards
+}
+
+var cipherSuites = map[string]uint16{
+	"TLS_RSA_WITH_AES_128_CBC_SHA":                  tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_RSA_WITH_AES_256_CBC_SHA":                  tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_RSA_WITH_AES_128_GCM_SHA256":               tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_RSA_WITH_AES_256_GCM_SHA384":               tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA":          tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA":          tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA":            tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA":            tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256":         tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256":       tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384":         tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384":       tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256":   tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256": tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+}
+
+var curves = map[string]tls.CurveID{
+	"CurveP256": tls.CurveP256,
+	"CurveP384": tls.CurveP384,
+	"CurveP521": tls.CurveP521,
+	"X25519":    tls.X25519,
+}
+
+// LoadConfig reads and validates the web config file in path
+func LoadConfig(path string) (*Config, error) {
+	cfg, _, err := loadConfig(path)
+	return cfg, err
+}
+
+// Also returns the TLS config built while validating, nil without certificate
+func loadConfig(path string) (*Config, *tls.Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		e := fmt.Sprintf("Trying to parse web config file [%s] with error [%s]", path, err)
+		return nil, nil, errors.New(e)
+	}
+	tlsConfig, err := cfg.validate()
+	if err != nil {
+		e := fmt.Sprintf("Trying to validate web config file [%s] with error [%s]", path, err)
+		return nil, nil, errors.New(e)
+	}
+	return cfg, tlsConfig, nil
+}
+
+func (cfg *Config) validate() (*tls.Config, error) {
+	for user, hash := range cfg.Users {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			e := fmt.Sprintf("Password of user [%s] isn't a bcrypt hash", user)
+			return nil, errors.New(e)
+		}
+	}
+	for _, hash := range cfg.BearerTokens {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, errors.New("Bearer token isn't a bcrypt hash")
+		}
+	}
+	return cfg.tlsConfig()
+}
+
+// Modification time and size of the config file in path and of the files it refers to, nil cfg
+// standing for a config not loaded yet
+func (cfg *Config) stamp(path string) string {
+	files := []string{path}
+	if cfg != nil {
+		files = append(files, cfg.TLSConfig.CertFile, cfg.TLSConfig.KeyFile, cfg.TLSConfig.ClientCAs)
+	}
+	var stamps []string
+	for _, file := range files {
+		stamp := ""
+		if info, err := os.Stat(file); err == nil {
+			stamp = fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size())
+		}
+		stamps = append(stamps, stamp)
+	}
+	return strings.Join(stamps, ",")
+}
+
+// Web config shared by connections and requests, loaded again once the file or the certificates
+// it refers to change
+type configCache struct {
+	path string
+
+	mu        sync.Mutex
+	stamp     string
+	cfg       *Config
+	tlsConfig *tls.Config
+}
+
+func (c *configCache) get() (*Config, *tls.Config, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Taken before loading so changes made meanwhile are loaded next time, the files referred to by a
+	// config are only known once loaded so it is loaded a second time when they change
+	stamp := c.cfg.stamp(c.path)
+	if c.cfg != nil && stamp == c.stamp {
+		return c.cfg, c.tlsConfig, nil
+	}
+	cfg, tlsConfig, err := loadConfig(c.path)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.stamp, c.cfg, c.tlsConfig = stamp, cfg, tlsConfig
+	return cfg, tlsConfig, nil
+}
+
+// Returns nil when no certificate is configured, loading the certificate and client CAs otherwise
+func (cfg *Config) tlsConfig() (*tls.Config, error) {
+	c := cfg.TLSConfig
+	if c.CertFile == "" && c.KeyFile == "" {
+		if c.ClientCAs != "" || c.ClientAuth != "" {
+			return nil, errors.New("Client authentication requires cert_file and key_file")
+		}
+		return nil, nil
+	}
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, errors.New("Both cert_file and key_file are required")
+	}
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		Certificates:             []tls.Certificate{cert},
+		PreferServerCipherSuites: c.PreferServerCipherSuites,
+		MinVersion:               tls.VersionTLS12,
+	}
+
+	var ok bool
+	if config.ClientAuth, ok = clientAuthTypes[c.ClientAuth]; !ok {
+		e := fmt.Sprintf("Unknown client_auth_type [%s]", c.ClientAuth)
+		return nil, errors.New(e)
+	}
+	if c.ClientCAs != "" {
+		data, err := ioutil.ReadFile(c.ClientCAs)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(data) {
+			e := fmt.Sprintf("No PEM certificate found within client_ca_file [%s]", c.ClientCAs)
+			return nil, errors.New(e)
+		}
+	} else if config.ClientAuth == tls.VerifyClientCertIfGiven || config.ClientAuth == tls.RequireAndVerifyClientCert {
+		return nil, errors.New("Verifying client certificates requires client_ca_file")
+	}
+
+	if c.MinVersion != "" {
+		if config.MinVersion, ok = tlsVersions[c.MinVersion]; !ok {
+			e := fmt.Sprintf("Unknown min_version [%s]", c.MinVersion)
+			return nil, errors.New(e)
+		}
+	}
+	if c.MaxVersion != "" {
+		if config.MaxVersion, ok = tlsVersions[c.MaxVersion]; !ok {
+			e := fmt.Sprintf("Unknown max_version [%s]", c.MaxVersion)
+			return nil, errors.New(e)
+		}
+	}
+	for _, name := range c.CipherSuites {
+		id, ok := cipherSuites[name]
+		if !ok {
+			e := fmt.Sprintf("Unknown cipher suite [%s]", name)
+			return nil, errors.New(e)
+		}
+		config.CipherSuites = append(config.CipherSuites, id)
+	}
+	for _, name := range c.CurvePreferences {
+		id, ok := curves[name]
+		if !ok {
+			e := fmt.Sprintf("Unknown curve [%s]", name)
+			return nil, errors.New(e)
+		}
+		config.CurvePreferences = append(config.CurvePreferences, id)
+	}
+
+	if cfg.HTTPConfig.HTTP2 == nil || *cfg.HTTPConfig.HTTP2 {
+		config.NextProtos = []string{"h2", "http/1.1"}
+	}
+	return config, nil
+}
+
+// ListenAndServe serves server as the web config file in path says, plain HTTP without any
+// authentication when path is empty. The file is read again once it or the certificates it refers
+// to change so certificates and users are replaced without a restart
+func ListenAndServe(server *http.Server, path string) error {
+	if path == "" {
+		return server.ListenAndServe()
+	}
+	config := &configCache{path: path}
+	cfg, _, err := config.get()
+	if err != nil {
+		return err
+	}
+
+	handler := server.Handler
+	if handler == nil {
+		handler = http.DefaultServeMux
+	}
+	server.Handler = &authHandler{config: config, handler: handler, cache: make(map[[sha256.Size]byte]bool)}
+
+	if cfg.TLSConfig.CertFile == "" {
+		return server.ListenAndServe()
+	}
+	if cfg.HTTPConfig.HTTP2 != nil && !*cfg.HTTPConfig.HTTP2 {
+		server.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	}
+	server.TLSConfig = &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			_, tlsConfig, err := config.get()
+			if err != nil {
+				log.Errorf("%s", err)
+				return nil, err
+			}
+			if tlsConfig == nil {
+				return nil, errors.New("No certificate configured anymore")
+			}
+			return tlsConfig, nil
+		},
+		// ServeTLS only leaves certificates to GetConfigForClient when there is a way to get them
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return nil, errors.New("Certificates are given by GetConfigForClient")
+		},
+	}
+	return server.ListenAndServeTLS("", "")
+}
+
+// Requires the basic auth users or bearer tokens of the web config, if any
+type authHandler struct {
+	config  *configCache
+	handler http.Handler
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]bool // bcrypt results by hash and secret, as comparing them is slow on purpose
+}
+
+// Unknown users are compared against this hash so they take as long as known ones
+const unknownUserHash = "$2a$10$kCku84YF2oCPGtL4TxcZbewa7LPcBFVf/mhy4OlITgzIKU.S875DS"
+
+func (h *authHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cfg, _, err := h.config.get()
+	if err != nil {
+		log.Errorf("%s", err)
+		http.Error(w, "Unable to load web config", http.StatusInternalServerError)
+		return
+	}
+	for name, value := range cfg.HTTPConfig.Header {
+		w.Header().Set(name, value)
+	}
+	if len(cfg.Users) == 0 && len(cfg.BearerTokens) == 0 {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+
+	if user, password, ok := r.BasicAuth(); ok {
+		hash, known := cfg.Users[user]
+		if !known {
+			hash = unknownUserHash
+		}
+		if h.matches(hash, password) && known {
+			h.handler.ServeHTTP(w, r)
+			return
+		}
+	} else if token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); token != r.Header.Get("Authorization") {
+		for _, hash := range cfg.BearerTokens {
+			if h.matches(hash, token) {
+				h.handler.ServeHTTP(w, r)
+				return
+			}
+		}
+	}
+
+	if len(cfg.Users) > 0 {
+		w.Header().Set("WWW-Authenticate", "Basic")
+	}
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+func (h *authHandler) matches(hash, secret string) bool {
+	key := sha256.Sum256([]byte(hash + "\x00" + secret))
+	h.mu.Lock()
+	match, cached := h.cache[key]
+	h.mu.Unlock()
+	if cached {
+		return match
+	}
+
+	match = bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret)) == nil
+	h.mu.Lock()
+	// Bounded so invalid credentials can't grow it forever
+	if len(h.cache) >= 1000 {
+		h.cache = make(map[[sha256.Size]byte]bool)
+	}
+	h.cache[key] = match
+	h.mu.Unlock()
+	return match
+}
@@ -0,0 +1,261 @@
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// bcrypt hashes of "secret" and "token"
+const (
+	secretHash = "$2a$10$2BloesKzUKqD1MeDcC.4K.F7l.arrAgU.QhOr74WH1j.OJCb.jFOe"
+	tokenHash  = "$2a$10$/3oP5cbF5q7BB/QKjHzWuOw73vro0z6es8xCDmjQFkJtRPKkoVEf6"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Writes a certificate and its key signed by parent, self-signed when parent is nil
+func writeCertificate(t *testing.T, dir, name string, template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	writeFile(t, dir, name+".crt", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+	writeFile(t, dir, name+".key", string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})))
+	return cert, key
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeCertificate(t, dir, "server", &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotAfter:     time.Now().Add(time.Hour),
+	}, nil, nil)
+	tls := fmt.Sprintf("tls_server_config:\n  cert_file: %s/server.crt\n  key_file: %s/server.key\n", dir, dir)
+
+	tests := []struct {
+		content string
+		err     string
+	}{
+		{"basic_auth_users:\n  alice: secret", "Password of user [alice] isn't a bcrypt hash"},
+		{"bearer_tokens: [token]", "Bearer token isn't a bcrypt hash"},
+		{"tls_server_config:\n  cert_file: server.crt", "Both cert_file and key_file are required"},
+		{"tls_server_config:\n  client_auth_type: RequireAndVerifyClientCert", "Client authentication requires cert_file and key_file"},
+		{tls + "  client_auth_type: RequireAndVerifyClientCert", "Verifying client certificates requires client_ca_file"},
+		{tls + "  client_auth_type: Always", "Unknown client_auth_type [Always]"},
+		{tls + "  min_version: SSL30", "Unknown min_version [SSL30]"},
+		{tls + "  cipher_suites: [TLS_NULL]", "Unknown cipher suite [TLS_NULL]"},
+		{tls + "  curve_preferences: [P192]", "Unknown curve [P192]"},
+		{"unknown: true", "field unknown not found"},
+	}
+
+	for _, test := range tests {
+		_, err := LoadConfig(writeFile(t, dir, "web.yml", test.content))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Expected error [%s] for %q, got [%v]", test.err, test.content, err)
+		}
+	}
+}
+
+func TestConfigCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := writeFile(t, dir, "web.yml", fmt.Sprintf("basic_auth_users:\n  alice: %s\n", secretHash))
+
+	cache := &configCache{path: path}
+	var loaded []*Config
+	for i := 0; i < 3; i++ {
+		cfg, _, err := cache.get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded = append(loaded, cfg)
+	}
+	if loaded[1] != loaded[2] {
+		t.Errorf("The config shouldn't be loaded again until the file changes")
+	}
+
+	writeFile(t, dir, "web.yml", fmt.Sprintf("basic_auth_users:\n  bob: %s\n", secretHash))
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	cfg, _, err := cache.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cfg.Users["bob"]; !ok {
+		t.Errorf("The config should be loaded again once the file changes")
+	}
+}
+
+func TestAuthHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := writeFile(t, dir, "web.yml", fmt.Sprintf(`
+basic_auth_users:
+  alice: %s
+bearer_tokens: [%s]
+http_server_config:
+  headers:
+    X-Frame-Options: deny
+`, secretHash, tokenHash))
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	ts := httptest.NewServer(&authHandler{config: &configCache{path: path}, handler: ok, cache: make(map[[32]byte]bool)})
+	defer ts.Close()
+
+	tests := []struct {
+		user, password, token string
+		status                int
+	}{
+		{"", "", "", http.StatusUnauthorized},
+		{"alice", "wrong", "", http.StatusUnauthorized},
+		{"bob", "secret", "", http.StatusUnauthorized},
+		{"alice", "secret", "", http.StatusOK},
+		{"alice", "secret", "", http.StatusOK}, // Cached
+		{"", "", "wrong", http.StatusUnauthorized},
+		{"", "", "token", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest("GET", ts.URL, nil)
+		if test.user != "" {
+			req.SetBasicAuth(test.user, test.password)
+		}
+		if test.token != "" {
+			req.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != test.status {
+			t.Errorf("Expected %d for %+v, got %d", test.status, test, res.StatusCode)
+		}
+		if res.Header.Get("X-Frame-Options") != "deny" {
+			t.Errorf("Configured headers should be set")
+		}
+		if res.StatusCode == http.StatusUnauthorized && res.Header.Get("WWW-Authenticate") != "Basic" {
+			t.Errorf("Basic auth should be requested")
+		}
+	}
+}
+
+func TestListenAndServeMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca, caKey := writeCertificate(t, dir, "ca", &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, nil)
+	writeCertificate(t, dir, "server", &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	writeCertificate(t, dir, "client", &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "prometheus"},
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+	path := writeFile(t, dir, "web.yml", fmt.Sprintf(`
+tls_server_config:
+  cert_file: %[1]s/server.crt
+  key_file: %[1]s/server.key
+  client_auth_type: RequireAndVerifyClientCert
+  client_ca_file: %[1]s/ca.crt
+`, dir))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+	server := &http.Server{Addr: address, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+	go ListenAndServe(server, path)
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	clientCert, err := tls.LoadX509KeyPair(filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, certs := range [][]tls.Certificate{nil, {clientCert}} {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+		var res *http.Response
+		for i := 0; i < 50; i++ {
+			if res, err = client.Get("https://" + address); err == nil || !strings.Contains(err.Error(), "connection refused") {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if len(certs) == 0 && err == nil {
+			t.Errorf("Clients without certificate should be refused")
+		}
+		if len(certs) > 0 {
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != http.StatusOK {
+				t.Errorf("Expected a 200 response, got %d", res.StatusCode)
+			}
+		}
+	}
+}